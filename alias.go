@@ -0,0 +1,73 @@
+package indentfile
+
+// AliasHandler is implemented by reflection contexts
+// that accept some directives under other names.
+// DirectiveAliases maps each alias to the directive it stands for,
+// such as "listen-port" to "port".
+// Aliases are resolved before looking for the directive's method.
+type AliasHandler interface {
+	DirectiveAliases() map[string]string
+}
+
+// DeprecationHandler is implemented by contexts
+// that have directives which should no longer be used.
+// DeprecatedDirectives maps each deprecated directive
+// to the directive that replaces it,
+// or to "" if there is no replacement.
+//
+// When a deprecated directive is used,
+// the Parser passes an error wrapping ErrDeprecated to its Warn function
+// before handling the directive as usual.
+// Deprecated names are typically also aliases of their replacement.
+type DeprecationHandler interface {
+	DeprecatedDirectives() map[string]string
+}
+
+// reservedMethods are the methods of the optional interfaces
+// a reflection context may implement,
+// which are never called as directives.
+// End is handled separately,
+// since it is only reserved with the signature of EndDirectiveHandler.
+var reservedMethods = map[string]bool{
+	"Directive":            true,
+	"ObjectDirective":      true,
+	"ArgsDirective":        true,
+	"ParseBlock":           true,
+	"RawBlock":             true,
+	"DirectivePragmas":     true,
+	"DirectiveAliases":     true,
+	"DeprecatedDirectives": true,
+	"UnknownDirective":     true,
+	"Includes":             true,
+}
+
+// resolveAlias returns the name of the directive
+// that name is an alias of on a reflection context,
+// or name if it isn't an alias.
+func (ctx methodDirectiveHandler) resolveAlias(name string) string {
+	value := ctx.value
+	if !value.IsValid() || !value.CanInterface() {
+		return name
+	}
+
+	if aliases, is := value.Interface().(AliasHandler); is {
+		if target, is := aliases.DirectiveAliases()[name]; is {
+			return target
+		}
+	}
+
+	return name
+}
+
+// deprecatedError reports the use of a deprecated directive,
+// suggesting its replacement if it has one.
+func deprecatedError(name, replacement string) error {
+	if replacement == "" {
+		return DirectiveErrorf("%w %q", ErrDeprecated, name)
+	}
+
+	err := DirectiveErrorf("%w %q (use %q instead)",
+		ErrDeprecated, name, replacement).(errorArg)
+	err.Suggestions = []string{replacement}
+	return err
+}
@@ -0,0 +1,79 @@
+package indentfile
+
+import (
+	"container/list"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type aliasCtx struct {
+	msgCtx
+}
+
+func (aliasCtx) DirectiveAliases() map[string]string {
+	return map[string]string{
+		"say":   "msg",
+		"print": "msg",
+	}
+}
+
+func (aliasCtx) DeprecatedDirectives() map[string]string {
+	return map[string]string{
+		"print":  "msg",
+		"object": "",
+	}
+}
+
+func TestAliases(t *testing.T) {
+	source := "say hello\nprint world\nmsg again\n"
+	ctx := aliasCtx{msgCtx{list.New(), ""}}
+
+	var warnings []error
+	err := (&Parser{Warn: func(err error) {
+		warnings = append(warnings, err)
+	}}).Parse(strings.NewReader(source), ctx)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	var messages []string
+	for node := ctx.messages.Front(); node != nil; node = node.Next() {
+		messages = append(messages, node.Value.(string))
+	}
+
+	expect := []string{"hello", "world", "again", "<end>"}
+	if !reflect.DeepEqual(messages, expect) {
+		t.Errorf("Got messages %q; want %q", messages, expect)
+	}
+
+	if len(warnings) != 1 {
+		t.Fatalf("Got warnings %v; want 1", warnings)
+	}
+
+	warning := warnings[0]
+	expectMsg := `deprecated directive at line 2:1: "print" (use "msg" instead)`
+	if !errors.Is(warning, ErrDeprecated) {
+		t.Errorf("Got warning %v; want %v", warning, ErrDeprecated)
+	} else if warning.Error() != expectMsg {
+		t.Errorf("Got warning %q; want %q", warning, expectMsg)
+	}
+
+	fixed, err := ApplyFixes([]byte(source), ErrorFixes(warning))
+	if err != nil {
+		t.Errorf("ApplyFixes returned error: %v", err)
+	} else if string(fixed) != "say hello\nmsg world\nmsg again\n" {
+		t.Errorf("ApplyFixes = %q", fixed)
+	}
+
+	warnings = nil
+	err = (&Parser{Warn: func(err error) {
+		warnings = append(warnings, err)
+	}}).Parse(strings.NewReader(`object {"text": "hi"}`), ctx)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	} else if len(warnings) != 1 || ErrorFixes(warnings[0]) != nil {
+		t.Errorf("Got warnings %v; want 1 without fixes", warnings)
+	}
+}
@@ -0,0 +1,48 @@
+package indentfile
+
+import (
+	"reflect"
+)
+
+// Block is the captured block of a directive:
+// the directives indented under it, with their locations.
+type Block []*Node
+
+var blockType = reflect.TypeOf(Block(nil))
+
+// Exec passes the directives in the block to context,
+// following the same rules as ParseTokens.
+// It may be called any number of times.
+// Use Parser.ParseNodes instead to replay the block with options.
+func (b Block) Exec(context interface{}) error {
+	return ParseNodes(b, context)
+}
+
+// CaptureFunc is returned by a directive
+// that needs to see its whole block before acting on it.
+// Instead of parsing the block as directives in a new context,
+// the parser reads the block into a Block
+// and passes it to the function.
+// The function is called even if the directive has no block,
+// in which case the Block is empty.
+//
+// With the reflection API,
+// a directive method with a Block parameter
+// is instead called once its block has been read.
+// Any context it returns is ignored.
+type CaptureFunc func(block Block) error
+
+// pendingCapture is a directive that is waiting for its block.
+type pendingCapture struct {
+	fn   CaptureFunc
+	line []Token
+}
+
+func (c *pendingCapture) run(children []*Node) error {
+	err := c.fn(Block(children))
+	if err != nil && ErrorLocation(err).Lineno == 0 {
+		err = locateError(err, c.line)
+	}
+
+	return err
+}
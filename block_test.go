@@ -0,0 +1,92 @@
+package indentfile
+
+import (
+	"container/list"
+	"errors"
+	"sort"
+	"testing"
+)
+
+type captureCtx struct {
+	msgCtx
+	// The files the captured directives were read from.
+	files []string
+}
+
+func (c *captureCtx) Sorted(block Block) error {
+	for _, node := range block {
+		c.files = append(c.files, node.File)
+	}
+
+	sorted := append(Block{}, block...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Words()[0] < sorted[j].Words()[0]
+	})
+
+	return sorted.Exec(c.msgCtx)
+}
+
+func (c *captureCtx) OneOf() CaptureFunc {
+	return func(block Block) error {
+		if len(block) != 1 {
+			return DirectiveErrorf("expected one directive, got %d", len(block))
+		}
+
+		return block.Exec(c.msgCtx)
+	}
+}
+
+func TestCaptureBlock(t *testing.T) {
+	expect := []string{
+		"a",
+		"b nested",
+		"<b end>",
+		"c",
+		"<end>",
+		"<end>",
+		"done",
+	}
+
+	doc, err := ReadDocumentFile("test_files/block/capture.txt")
+	if err != nil {
+		t.Fatalf("ReadDocumentFile returned error: %v", err)
+	}
+
+	parsers := map[string]func(ctx *captureCtx) error{
+		"ParseFile": func(ctx *captureCtx) error {
+			return ParseFile("test_files/block/capture.txt", ctx)
+		},
+		"ParseDocument": func(ctx *captureCtx) error {
+			return ParseDocument(doc, ctx)
+		},
+	}
+
+	for name, parse := range parsers {
+		ctx := &captureCtx{msgCtx: msgCtx{list.New(), ""}}
+		err := parse(ctx)
+		if !errors.Is(err, ErrDirective) {
+			t.Errorf("%s got error %v; want %v", name, err, ErrDirective)
+		} else if loc := ErrorLocation(err); loc.Lineno != 8 || loc.Offset != 1 {
+			t.Errorf("%s got error at %d:%d; want 8:1", name, loc.Lineno, loc.Offset)
+		}
+
+		node := ctx.messages.Front()
+		for i, msg := range expect {
+			if node == nil || node.Value.(string) != msg {
+				t.Fatalf("%s got %d = %v; want %q", name, i, node, msg)
+			}
+
+			node = node.Next()
+		}
+
+		for _, file := range ctx.files {
+			if file != "test_files/block/capture.txt" {
+				t.Errorf("%s captured a directive from file %q", name, file)
+			}
+		}
+
+		if len(ctx.files) == 0 {
+			t.Errorf("%s captured no directives", name)
+		}
+	}
+}
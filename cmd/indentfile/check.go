@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/nelsonxb/indentfile"
+)
+
+// The most fixes applied to one file,
+// in case a fix doesn't remove its error.
+const maxFixes = 100
+
+func runCheck(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	fix := flags.Bool("fix", false, "apply suggested fixes to the files")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: indentfile check [-fix] <file>...\n")
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "indentfile: %v\n", err)
+			return 2
+		}
+
+		err = check(src, path)
+		fixed := src
+		for i := 0; *fix && err != nil && i < maxFixes; i++ {
+			fixes := indentfile.ErrorFixes(err)
+			if len(fixes) == 0 {
+				break
+			}
+
+			next, fixErr := indentfile.ApplyFixes(fixed, fixes[:1])
+			if fixErr != nil {
+				break
+			}
+
+			fmt.Printf("%s: %s\n", position(err, path), fixes[0].Message)
+			fixed = next
+			err = check(fixed, path)
+		}
+
+		if !bytes.Equal(fixed, src) {
+			info, statErr := os.Stat(path)
+			if statErr != nil {
+				fmt.Fprintf(os.Stderr, "indentfile: %v\n", statErr)
+				return 2
+			}
+
+			writeErr := os.WriteFile(path, fixed, info.Mode())
+			if writeErr != nil {
+				fmt.Fprintf(os.Stderr, "indentfile: %v\n", writeErr)
+				return 2
+			}
+		}
+
+		if err != nil {
+			status = 1
+			fmt.Printf("%v\n", err)
+		}
+	}
+
+	return status
+}
+
+// check reads src as an indentfile, returning the first error.
+func check(src []byte, path string) error {
+	_, err := indentfile.ReadDocument(bytes.NewReader(src))
+	return indentfile.ErrorInFile(err, path)
+}
+
+func position(err error, path string) string {
+	loc := indentfile.ErrorLocation(err)
+	return fmt.Sprintf("%s:%d:%d", path, loc.Lineno, loc.Column())
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nelsonxb/indentfile"
+)
+
+type keysFlag indentfile.MatchKeys
+
+func (keys keysFlag) String() string {
+	return ""
+}
+
+func (keys keysFlag) Set(value string) error {
+	eq := strings.LastIndexByte(value, '=')
+	if eq < 0 {
+		keys[value] = 0
+		return nil
+	}
+
+	n, err := strconv.Atoi(value[eq+1:])
+	if err != nil || n < 0 {
+		return fmt.Errorf("bad key count in %q", value)
+	}
+
+	keys[value[:eq]] = n
+	return nil
+}
+
+func runDiff(args []string) int {
+	keys := indentfile.MatchKeys{}
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.Var(keysFlag(keys), "key",
+		"match `name[=n]` directives by their first n arguments (repeatable)")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: indentfile diff [-key name=n]... <old> <new>\n")
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	a, err := indentfile.ReadDocumentFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "indentfile: %v\n", err)
+		return 2
+	}
+
+	b, err := indentfile.ReadDocumentFile(flags.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "indentfile: %v\n", err)
+		return 2
+	}
+
+	changes := indentfile.Diff(a, b, keys)
+	for _, change := range changes {
+		node := change.New
+		if node == nil {
+			node = change.Old
+		}
+
+		fmt.Printf("%s:%d:%d: %s\n", node.File,
+			node.Location.Lineno, node.Location.Column(), change)
+	}
+
+	if len(changes) > 0 {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,54 @@
+// Command indentfile provides tools for working with indentfiles.
+//
+// Usage:
+//
+//	indentfile <command> [arguments]
+//
+// The commands are:
+//
+//	query    print the directives matching a query
+//	diff     compare the directives in two files
+//	check    report syntax errors, optionally fixing them
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+var commands = []command{
+	{"query", "print the directives matching a query", runQuery},
+	{"diff", "compare the directives in two files", runDiff},
+	{"check", "report syntax errors, optionally fixing them", runCheck},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "indentfile: unknown command %q\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: indentfile <command> [arguments]\n\n")
+	fmt.Fprintf(os.Stderr, "The commands are:\n\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "\t%-8s %s\n", cmd.name, cmd.summary)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/nelsonxb/indentfile"
+)
+
+type queryResult struct {
+	File   string        `json:"file,omitempty"`
+	Line   int           `json:"line"`
+	Column int           `json:"column"`
+	Name   string        `json:"name"`
+	Args   []interface{} `json:"args"`
+}
+
+func runQuery(args []string) int {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print matches as a JSON array")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: indentfile query [-json] <query> <file>...\n")
+		flags.PrintDefaults()
+	}
+
+	flags.Parse(args)
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return 2
+	}
+
+	query, err := indentfile.CompileQuery(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "indentfile: %v\n", err)
+		return 2
+	}
+
+	status := 1
+	results := []queryResult{}
+	for _, path := range flags.Args()[1:] {
+		doc, err := indentfile.ReadDocumentFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "indentfile: %v\n", err)
+			return 2
+		}
+
+		for _, node := range query.Find(doc) {
+			status = 0
+			if *asJSON {
+				results = append(results, newQueryResult(node))
+			} else {
+				fmt.Printf("%s:%d:%d: %s\n", node.File,
+					node.Location.Lineno, node.Location.Column(), node)
+			}
+		}
+	}
+
+	if *asJSON {
+		out, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "indentfile: %v\n", err)
+			return 2
+		}
+
+		fmt.Printf("%s\n", out)
+	}
+
+	return status
+}
+
+func newQueryResult(node *indentfile.Node) queryResult {
+	result := queryResult{
+		File:   node.File,
+		Line:   node.Location.Lineno,
+		Column: node.Location.Column(),
+		Name:   node.Name,
+		Args:   make([]interface{}, len(node.Args)),
+	}
+
+	for i, arg := range node.Args {
+		if arg.JSON {
+			result.Args[i] = json.RawMessage(arg.Text)
+		} else {
+			result.Args[i] = arg.Text
+		}
+	}
+
+	return result
+}
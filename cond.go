@@ -0,0 +1,342 @@
+package indentfile
+
+import (
+	"os"
+	"strings"
+)
+
+// Conditions holds the values that conditional directives can test.
+// See WithConditions for the syntax.
+type Conditions struct {
+	// Variables, available in expressions as $name.
+	Vars map[string]string
+	// LookupEnv looks up environment variables,
+	// available in expressions as $env.NAME.
+	// If nil, os.LookupEnv is used.
+	LookupEnv func(key string) (string, bool)
+}
+
+// WithConditions wraps context so that conditional directives
+// are evaluated before any directive reaches it:
+//
+//	if $mode == prod
+//	    listen 80
+//	elif defined $env.DEBUG
+//	    listen 8000
+//	else
+//	    listen 8080
+//
+// The directives in the block of the first "if" or "elif"
+// whose expression is true are passed to the enclosing context,
+// as though they were written in place of the conditional.
+// The directives in all other blocks are still read by the Tokenizer,
+// so syntax errors are reported, but they are never passed on.
+// Conditionals may appear in any block, and may be nested.
+// The Parser looks through the wrapper for the interfaces
+// that context implements, such as UnknownDirectiveHandler,
+// and applies its Names to the wrapped contexts.
+// Blocks with their own grammar, such as raw blocks,
+// are passed on unchanged.
+//
+// An expression is made of words, evaluated as follows:
+//
+//	$name, $env.NAME   the value of a variable,
+//	                   or an empty string if it is not set
+//	defined X          whether the variable X is set
+//	X == Y, X != Y     whether the values are equal
+//	X                  whether the value is not empty
+//	!, not, and, or    boolean operators, in order of precedence
+//	( ... )            grouping
+//
+// Words not starting with "$" are literal strings.
+func WithConditions(context interface{}, conds *Conditions) ObjectDirectiveHandler {
+	if conds == nil {
+		conds = &Conditions{}
+	}
+
+	return &conditionalHandler{
+		conds:   conds,
+		context: context,
+		handler: getDirectiveHandlerFor(context, nil),
+		ends:    true,
+	}
+}
+
+const (
+	noCondition = iota
+	conditionTaken
+	conditionNotTaken
+)
+
+type conditionalHandler struct {
+	conds   *Conditions
+	context interface{}
+	handler ArgsDirectiveHandler
+	// The mapping handler uses, for the contexts of blocks.
+	names *NameMapping
+	// Whether this handler owns the context,
+	// and so should pass on End.
+	// The blocks of conditionals share the enclosing context,
+	// which will be ended by the enclosing block instead.
+	ends  bool
+	state int
+}
+
+func (h *conditionalHandler) Directive(name string, argv []string) (interface{}, error) {
+	return h.ArgsDirective(name, joinArgs(argv, nil))
+}
+
+func (h *conditionalHandler) ObjectDirective(name string, argv []string, json []byte) (interface{}, error) {
+	return h.ArgsDirective(name, joinArgs(argv, json))
+}
+
+func (h *conditionalHandler) ArgsDirective(name string, args []Arg) (interface{}, error) {
+	switch name {
+	case "if", "elif", "else":
+	default:
+		h.state = noCondition
+		block, err := h.handler.ArgsDirective(name, args)
+		if block == nil || err != nil {
+			return block, err
+		}
+
+		switch block.(type) {
+		case RawBlockHandler, TokenBlockHandler, CaptureFunc, skippedBlock:
+			// The block is not parsed as directives by this context,
+			// so there are no conditionals in it to evaluate.
+			return block, nil
+		}
+
+		return &conditionalHandler{
+			conds:   h.conds,
+			context: block,
+			handler: getDirectiveHandlerFor(block, h.names),
+			names:   h.names,
+			ends:    true,
+		}, nil
+	}
+
+	argv, objects, _, objIndexes := splitArgs(args)
+	if len(objects) > 0 {
+		return nil, ArgumentErrorf(objIndexes[0], "%w", ErrArgumentJSON)
+	}
+
+	if name == "if" {
+		h.state = noCondition
+	} else if h.state == noCondition {
+		return nil, DirectiveErrorf("%q without matching if", name)
+	}
+
+	if name == "else" {
+		if len(argv) > 0 {
+			return nil, ArgumentErrorf(0, "too many arguments")
+		}
+	} else if len(argv) == 0 {
+		return nil, ArgumentErrorf(0, "expected condition")
+	}
+
+	taken := false
+	if name != "else" {
+		var err error
+		taken, err = h.conds.evaluate(argv)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if name == "else" {
+		// An else ends the chain.
+		taken = h.state == conditionNotTaken
+		h.state = noCondition
+	} else if h.state == conditionTaken {
+		taken = false
+	} else if taken {
+		h.state = conditionTaken
+	} else {
+		h.state = conditionNotTaken
+	}
+
+	if !taken {
+		return skippedBlock{}, nil
+	}
+
+	return &conditionalHandler{
+		conds:   h.conds,
+		context: h.context,
+		handler: h.handler,
+		names:   h.names,
+	}, nil
+}
+
+func (h *conditionalHandler) wrappedContext() interface{} {
+	return h.context
+}
+
+func (h *conditionalHandler) withNames(names *NameMapping) ArgsDirectiveHandler {
+	named := *h
+	named.handler = getDirectiveHandlerFor(h.context, names)
+	named.names = names
+	return &named
+}
+
+func (h *conditionalHandler) End() error {
+	if ender, is := h.context.(EndDirectiveHandler); is && h.ends {
+		return ender.End()
+	}
+
+	return nil
+}
+
+// skippedBlock accepts and discards every directive in a block.
+type skippedBlock struct{}
+
+func (skippedBlock) Directive(name string, argv []string) (interface{}, error) {
+	return skippedBlock{}, nil
+}
+
+func (skippedBlock) ObjectDirective(name string, argv []string, json []byte) (interface{}, error) {
+	return skippedBlock{}, nil
+}
+
+func (skippedBlock) ArgsDirective(name string, args []Arg) (interface{}, error) {
+	return skippedBlock{}, nil
+}
+
+func (conds *Conditions) evaluate(argv []string) (bool, error) {
+	e := condExpr{conds: conds, words: argv}
+	result, err := e.or()
+	if err == nil && e.at < len(argv) {
+		err = ArgumentErrorf(e.at, "unexpected %q in condition", argv[e.at])
+	}
+
+	return result, err
+}
+
+type condExpr struct {
+	conds *Conditions
+	words []string
+	at    int
+}
+
+func (e *condExpr) peek() string {
+	if e.at < len(e.words) {
+		return e.words[e.at]
+	}
+
+	return ""
+}
+
+func (e *condExpr) next() (string, error) {
+	if e.at >= len(e.words) {
+		return "", ArgumentErrorf(e.at, "condition ended unexpectedly")
+	}
+
+	e.at++
+	return e.words[e.at-1], nil
+}
+
+func (e *condExpr) or() (bool, error) {
+	result, err := e.and()
+	for err == nil && e.peek() == "or" {
+		e.at++
+		var rhs bool
+		rhs, err = e.and()
+		result = result || rhs
+	}
+
+	return result, err
+}
+
+func (e *condExpr) and() (bool, error) {
+	result, err := e.not()
+	for err == nil && e.peek() == "and" {
+		e.at++
+		var rhs bool
+		rhs, err = e.not()
+		result = result && rhs
+	}
+
+	return result, err
+}
+
+func (e *condExpr) not() (bool, error) {
+	if e.peek() == "!" || e.peek() == "not" {
+		e.at++
+		result, err := e.not()
+		return !result, err
+	}
+
+	return e.primary()
+}
+
+func (e *condExpr) primary() (bool, error) {
+	word, err := e.next()
+	if err != nil {
+		return false, err
+	}
+
+	switch word {
+	case "(":
+		result, err := e.or()
+		if err != nil {
+			return false, err
+		}
+
+		if e.peek() != ")" {
+			return false, ArgumentErrorf(e.at, "expected ) in condition")
+		}
+
+		e.at++
+		return result, nil
+
+	case "defined":
+		name, err := e.next()
+		if err != nil {
+			return false, err
+		} else if !strings.HasPrefix(name, "$") {
+			return false, ArgumentErrorf(e.at-1, "expected variable")
+		}
+
+		_, has := e.conds.lookup(name[1:])
+		return has, nil
+	}
+
+	value := e.conds.expand(word)
+	switch e.peek() {
+	case "==", "!=":
+		op := e.peek()
+		e.at++
+
+		rhs, err := e.next()
+		if err != nil {
+			return false, err
+		}
+
+		return (value == e.conds.expand(rhs)) == (op == "=="), nil
+	}
+
+	return value != "", nil
+}
+
+func (conds *Conditions) expand(word string) string {
+	if !strings.HasPrefix(word, "$") {
+		return word
+	}
+
+	value, _ := conds.lookup(word[1:])
+	return value
+}
+
+func (conds *Conditions) lookup(name string) (string, bool) {
+	if strings.HasPrefix(name, "env.") {
+		lookupEnv := conds.LookupEnv
+		if lookupEnv == nil {
+			lookupEnv = os.LookupEnv
+		}
+
+		return lookupEnv(name[len("env."):])
+	}
+
+	value, has := conds.Vars[name]
+	return value, has
+}
@@ -0,0 +1,233 @@
+package indentfile
+
+import (
+	"container/list"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConditions(t *testing.T) {
+	cases := []struct {
+		vars   map[string]string
+		env    map[string]string
+		expect []string
+	}{
+		{
+			map[string]string{"mode": "prod", "verbose": "yes"},
+			nil,
+			[]string{"start", "production", "nested verbose",
+				"nested verbose production", "<nested end>", "<end>"},
+		},
+		{
+			map[string]string{"mode": "dev"},
+			map[string]string{"DEBUG": ""},
+			[]string{"start", "debugging", "<nested end>", "<end>"},
+		},
+		{
+			map[string]string{"mode": "test"},
+			nil,
+			[]string{"start", "unknown", "<nested end>", "<end>"},
+		},
+		{
+			map[string]string{"mode": "dev", "verbose": "1"},
+			nil,
+			[]string{"start", "development", "nested verbose",
+				"nested verbose other", "<nested end>", "<end>"},
+		},
+	}
+
+	for i, c := range cases {
+		messages := list.New()
+		conds := &Conditions{
+			Vars: c.vars,
+			LookupEnv: func(key string) (string, bool) {
+				value, has := c.env[key]
+				return value, has
+			},
+		}
+
+		err := ParseFile("test_files/cond/modes.txt",
+			WithConditions(&msgCtx{messages, ""}, conds))
+		if err != nil {
+			t.Errorf("Case %d: ParseFile returned error: %v", i, err)
+			continue
+		}
+
+		var actual []string
+		for node := messages.Front(); node != nil; node = node.Next() {
+			actual = append(actual, node.Value.(string))
+		}
+
+		if strings.Join(actual, "|") != strings.Join(c.expect, "|") {
+			t.Errorf("Case %d: got %q; want %q", i, actual, c.expect)
+		}
+	}
+}
+
+func TestConditionErrors(t *testing.T) {
+	cases := []struct {
+		source string
+		err    error
+		offset int
+	}{
+		{"elif x\n", ErrDirective, 1},
+		{"if x\nmsg hi\nelse\n", ErrDirective, 1},
+		{"if\n", ErrArguments, 3},
+		{"if x y\n", ErrArguments, 6},
+		{"if ( x\n", ErrArguments, 7},
+		{"if defined x\n", ErrArguments, 12},
+		{"if x\nelse y\n", ErrArguments, 6},
+		{"if x {}\n", ErrArgumentJSON, 6},
+		{"if $unset\n    msg \"unclosed\n", ErrUnquote, 0},
+	}
+
+	for _, c := range cases {
+		err := Parse(strings.NewReader(c.source),
+			WithConditions(&msgCtx{list.New(), ""}, nil))
+		if !errors.Is(err, c.err) {
+			t.Errorf("Parse(%q) error = %v; want %v", c.source, err, c.err)
+		} else if c.offset != 0 && ErrorLocation(err).Offset != c.offset {
+			t.Errorf("Parse(%q) error at offset %d; want %d",
+				c.source, ErrorLocation(err).Offset, c.offset)
+		}
+	}
+}
+
+type rawRecorder struct {
+	text string
+}
+
+func (r *rawRecorder) RawBlock(text Token) error {
+	r.text = string(text.Text())
+	return nil
+}
+
+func TestConditionalRawBlocks(t *testing.T) {
+	source := "if $on\n    script sh\n        echo $on\n    text\n        if (\n"
+	conds := &Conditions{Vars: map[string]string{"on": "1"}}
+
+	ctx := &rawCtx{msgCtx: msgCtx{list.New(), ""}}
+	recorder := &rawRecorder{}
+	err := Parse(strings.NewReader(source), WithConditions(ArgsHandlerFunc(
+		func(name string, args []Arg) (interface{}, error) {
+			if name == "text" {
+				return recorder, nil
+			}
+
+			return getDirectiveHandlerFor(ctx, nil).ArgsDirective(name, args)
+		}), conds))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	expect := "sh:echo $on\n"
+	if len(ctx.scripts) != 1 || ctx.scripts[0] != expect {
+		t.Errorf("Got scripts %q; want [%q]", ctx.scripts, expect)
+	}
+
+	if expect = "if (\n"; recorder.text != expect {
+		t.Errorf("Got raw text %q; want %q", recorder.text, expect)
+	}
+}
+
+func TestConditionalTokenBlock(t *testing.T) {
+	source := "if $on\n    table\n        name | port\n        api | 80\n"
+	conds := &Conditions{Vars: map[string]string{"on": "1"}}
+
+	messages := list.New()
+	err := Parse(strings.NewReader(source),
+		WithConditions(&tableCtx{msgCtx{messages, ""}}, conds))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	var actual []string
+	for node := messages.Front(); node != nil; node = node.Next() {
+		actual = append(actual, node.Value.(string))
+	}
+
+	expect := []string{"name port", "api 80", "<end>"}
+	if strings.Join(actual, "|") != strings.Join(expect, "|") {
+		t.Errorf("Got %q; want %q", actual, expect)
+	}
+}
+
+func TestConditionalSkippedBlock(t *testing.T) {
+	skipper := HandlerFunc(func(name string, argv []string) (interface{}, error) {
+		return skippedBlock{}, nil
+	})
+
+	// Conditionals in a skipped block are never evaluated.
+	err := Parse(strings.NewReader("skip\n    if (\n        msg hi\n"),
+		WithConditions(skipper, nil))
+	if err != nil {
+		t.Errorf("Parse returned error: %v", err)
+	}
+}
+
+func TestConditionalContext(t *testing.T) {
+	conds := &Conditions{Vars: map[string]string{"on": "1"}}
+	source := "#@ experimental\nif $on\n    sorted\n        msg b\n        msg a\n" +
+		"    future-thing\n        nested stuff\n"
+
+	checkMessages := func(name string, ctx msgCtx, expect ...string) {
+		var messages []string
+		for node := ctx.messages.Front(); node != nil; node = node.Next() {
+			messages = append(messages, node.Value.(string))
+		}
+
+		if strings.Join(messages, "|") != strings.Join(expect, "|") {
+			t.Errorf("%s: got %q; want %q", name, messages, expect)
+		}
+	}
+
+	type ctx struct {
+		captureCtx
+		pragmaCtx
+		Unknown []*Node
+	}
+
+	c := &ctx{}
+	c.captureCtx.msgCtx = msgCtx{list.New(), ""}
+	c.pragmaCtx.msgCtx = c.captureCtx.msgCtx
+	err := (&Parser{Unknown: UnknownCollect}).Parse(strings.NewReader(source),
+		WithConditions(c, conds))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	checkMessages("Sorted", c.captureCtx.msgCtx, "a", "b", "<end>")
+	if len(c.seen) != 1 {
+		t.Errorf("Got pragmas %q; want 1", c.seen)
+	}
+
+	if len(c.Unknown) != 1 || c.Unknown[0].String() != "future-thing" {
+		t.Errorf("Got unknown directives %v", c.Unknown)
+	}
+
+	unknown := &unknownCtx{msgCtx{list.New(), ""}}
+	err = Parse(strings.NewReader(source), WithConditions(unknown, conds))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	checkMessages("UnknownDirective", unknown.msgCtx,
+		"unknown sorted", "unknown future-thing", "<end>")
+}
+
+func TestConditionalNames(t *testing.T) {
+	var called []string
+	conds := &Conditions{Vars: map[string]string{"on": "1"}}
+	p := &Parser{Names: &NameMapping{Separators: "_"}}
+
+	err := p.Parse(strings.NewReader("if $on\n    listen_addr :80\n"),
+		WithConditions(namesCtx{&called}, conds))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	if len(called) != 1 || called[0] != "ListenAddr :80" {
+		t.Errorf("Called %q; want [\"ListenAddr :80\"]", called)
+	}
+}
@@ -0,0 +1,168 @@
+package indentfile
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// ChangeKind enumerates the kinds of difference reported by Diff.
+type ChangeKind int
+
+// The kinds of Change.
+const (
+	// The directive only exists in the new document.
+	Added ChangeKind = iota + 1
+	// The directive only exists in the old document.
+	Removed
+	// The directive exists in both, but its arguments differ.
+	Changed
+)
+
+// Change is a single difference between two documents.
+type Change struct {
+	Kind ChangeKind
+	// The directive in the old document, or nil if it was Added.
+	Old *Node
+	// The directive in the new document, or nil if it was Removed.
+	New *Node
+	// The directives containing this one, outermost first,
+	// taken from the new document where possible.
+	Path []*Node
+}
+
+// String formats the change as a single line,
+// starting with "+", "-" or "~".
+func (c Change) String() string {
+	var b strings.Builder
+	switch c.Kind {
+	case Added:
+		b.WriteString("+ ")
+	case Removed:
+		b.WriteString("- ")
+	default:
+		b.WriteString("~ ")
+	}
+
+	for _, parent := range c.Path {
+		b.WriteString(parent.String())
+		b.WriteString(" / ")
+	}
+
+	switch c.Kind {
+	case Added:
+		b.WriteString(c.New.String())
+	case Removed:
+		b.WriteString(c.Old.String())
+	default:
+		b.WriteString(c.Old.String())
+		b.WriteString(" -> ")
+		b.WriteString(c.New.String())
+	}
+
+	return b.String()
+}
+
+// Diff compares two documents,
+// ignoring formatting, comments, quoting style and JSON whitespace.
+//
+// Directives in the same block are matched using keys.
+// Directives with an entry in keys are matched by their key arguments,
+// while the others are matched by position:
+// the first "listen" in a block of a is matched
+// with the first "listen" in the same block of b, and so on.
+// Matched directives are reported as Changed if their arguments differ,
+// with JSON arguments compared by value,
+// and their blocks are then compared in turn.
+// Added and Removed directives are reported without their blocks.
+func Diff(a, b *Document, keys MatchKeys) []Change {
+	return diffNodes(nil, a.Children, b.Children, keys)
+}
+
+func diffNodes(path []*Node, a, b []*Node, keys MatchKeys) (changes []Change) {
+	matches := make([]int, len(a))
+	matched := make([]bool, len(b))
+
+	for i, before := range a {
+		matches[i] = -1
+		_, keyed := keys[before.Name]
+
+		for j, after := range b {
+			if matched[j] || after.Name != before.Name {
+				continue
+			}
+
+			// Without keys, the first unmatched directive of the same name
+			// is the one in the same position.
+			if keyed && !keys.match(before, after) {
+				continue
+			}
+
+			matches[i] = j
+			matched[j] = true
+			break
+		}
+	}
+
+	for i, before := range a {
+		if matches[i] < 0 {
+			changes = append(changes, Change{Kind: Removed, Old: before, Path: path})
+		}
+	}
+
+	befores := make([]*Node, len(b))
+	for i, j := range matches {
+		if j >= 0 {
+			befores[j] = a[i]
+		}
+	}
+
+	for j, after := range b {
+		before := befores[j]
+		if before == nil {
+			changes = append(changes, Change{Kind: Added, New: after, Path: path})
+			continue
+		}
+
+		if !equalArgs(before, after) {
+			changes = append(changes, Change{
+				Kind: Changed, Old: before, New: after, Path: path,
+			})
+		}
+
+		childPath := append(path[:len(path):len(path)], after)
+		changes = append(changes,
+			diffNodes(childPath, before.Children, after.Children, keys)...)
+	}
+
+	return
+}
+
+func equalArgs(a, b *Node) bool {
+	if len(a.Args) != len(b.Args) {
+		return false
+	}
+
+	for i := range a.Args {
+		argA, argB := a.Args[i], b.Args[i]
+		if argA.JSON != argB.JSON {
+			return false
+		} else if !argA.JSON && argA.Text != argB.Text {
+			return false
+		} else if argA.JSON && !equalJSON(argA.Text, argB.Text) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equalJSON(a, b string) bool {
+	var valueA, valueB interface{}
+	if json.Unmarshal([]byte(a), &valueA) != nil ||
+		json.Unmarshal([]byte(b), &valueB) != nil {
+		return a == b
+	}
+
+	return reflect.DeepEqual(valueA, valueB)
+}
@@ -0,0 +1,48 @@
+package indentfile
+
+import (
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a, err := ReadDocumentFile("test_files/diff/old.txt")
+	if err != nil {
+		t.Fatalf("ReadDocumentFile returned error: %v", err)
+	}
+
+	b, err := ReadDocumentFile("test_files/diff/new.txt")
+	if err != nil {
+		t.Fatalf("ReadDocumentFile returned error: %v", err)
+	}
+
+	testDiff(t, Diff(a, b, nil), []string{
+		"~ server api / listen 443 -> listen 8443",
+		`+ server api / route /v2 {"backend": "v2"}`,
+		"~ server web -> server admin",
+		"~ server admin / listen 8080 -> listen 81",
+	})
+
+	testDiff(t, Diff(a, b, MatchKeys{"server": 1, "listen": 1}), []string{
+		"- server web",
+		"- server api / listen 443",
+		"+ server api / listen 8443",
+		`+ server api / route /v2 {"backend": "v2"}`,
+		"+ server admin",
+	})
+}
+
+func testDiff(t *testing.T, changes []Change, expect []string) {
+	if len(changes) != len(expect) {
+		for _, change := range changes {
+			t.Logf("Change: %s", change)
+		}
+
+		t.Fatalf("Got %d changes; want %d", len(changes), len(expect))
+	}
+
+	for i, change := range changes {
+		if change.String() != expect[i] {
+			t.Errorf("Change %d = %q; want %q", i, change.String(), expect[i])
+		}
+	}
+}
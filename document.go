@@ -0,0 +1,331 @@
+package indentfile
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+)
+
+// Document is a fully parsed indentfile,
+// held in memory as a tree of directives.
+type Document struct {
+	// The file the document was read from, if known.
+	File string
+	// The top-level directives of the document.
+	Children []*Node
+	// The file-level pragmas of the document.
+	Pragmas []Pragma
+}
+
+// Node is a single directive in a Document.
+type Node struct {
+	// The name of the directive.
+	Name string
+	// The arguments following the name, in source order.
+	Args []Arg
+	// The directives in the block under this one.
+	Children []*Node
+	// The file this directive was read from, if known.
+	File string
+	// The location of the directive name.
+	Location LineInfo
+	// The "use" directive this one was expanded from
+	// by ExpandMacros, if any.
+	ExpandedFrom *Node
+	// The directive pragmas attached to this directive.
+	Pragmas []Pragma
+	// The text of the comment lines immediately before this directive,
+	// without their comment characters, joined by newlines.
+	// Pragmas are not included.
+	Doc string
+	// The text of the comment at the end of this directive's line,
+	// without its comment character.
+	LineComment string
+
+	end LineInfo
+}
+
+// Arg is a single argument of a directive.
+type Arg struct {
+	// The shell-parsed text of a word argument,
+	// or the source of a JSON argument.
+	Text string
+	// Whether this argument is a JSON object or array.
+	JSON bool
+	// The location of the start of the argument.
+	Location LineInfo
+}
+
+// ReadDocument reads a complete Document from r.
+func ReadDocument(r io.Reader) (*Document, error) {
+	return ReadDocumentTokens(NewTokenizer(r))
+}
+
+// ReadDocumentFile reads a complete Document from the file at path.
+// As with ParseFile, a path of "-" reads from standard input.
+func ReadDocumentFile(path string) (doc *Document, err error) {
+	var r io.ReadCloser
+	if path == "-" {
+		r = os.Stdin
+		path = "<stdin>"
+	} else {
+		r, err = os.Open(path)
+		if err != nil {
+			return
+		}
+
+		defer r.Close()
+	}
+
+	tok := NewTokenizer(r)
+	doc = &Document{File: path}
+	doc.Children, err = readNodes(tok, path)
+	if err != nil {
+		return nil, ErrorInFile(err, path)
+	}
+
+	doc.Pragmas = tok.FilePragmas()
+	return
+}
+
+// ReadDocumentTokens reads a complete Document from a token stream.
+func ReadDocumentTokens(tok *Tokenizer) (doc *Document, err error) {
+	doc = &Document{}
+	doc.Children, err = readNodes(tok, "")
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Pragmas = tok.FilePragmas()
+	return
+}
+
+// documentReader builds nodes from a token stream,
+// keeping track of comments between blocks.
+type documentReader struct {
+	tok  *Tokenizer
+	file string
+
+	// Comment lines that may document the next directive.
+	doc        []string
+	docLineno  int
+	lastLineno int
+	last       *Node
+}
+
+func readNodes(tok *Tokenizer, file string) ([]*Node, error) {
+	r := &documentReader{tok: tok, file: file}
+	return r.readBlock()
+}
+
+func (r *documentReader) readBlock() (nodes []*Node, err error) {
+	var token Token
+	var node, last *Node
+
+tokenLoop:
+	for token, err = r.tok.Next(); err == nil; token, err = r.tok.Next() {
+		switch token.Type() {
+		case WordToken:
+			if node == nil {
+				node = &Node{
+					Name:     string(token.Text()),
+					File:     r.file,
+					Location: token.LineInfo(0),
+				}
+
+				if r.docLineno == node.Location.Lineno-1 {
+					node.Doc = strings.Join(r.doc, "\n")
+				}
+
+				r.doc = nil
+			} else {
+				node.Args = append(node.Args, Arg{
+					Text:     string(token.Text()),
+					Location: token.LineInfo(0),
+				})
+			}
+
+		case ObjectToken:
+			node.Args = append(node.Args, Arg{
+				Text:     string(token.Text()),
+				JSON:     true,
+				Location: token.LineInfo(0),
+			})
+
+		case TerminatorToken:
+			node.end = token.LineInfo(0)
+			node.Pragmas = r.tok.TakePragmas()
+			nodes = append(nodes, node)
+			last = node
+			r.last = node
+			node = nil
+
+		case IndentToken:
+			if last == nil {
+				return nil, errorAt(ErrIndent, token.LineInfo(0))
+			}
+
+			last.Children, err = r.readBlock()
+			if err != nil {
+				return nil, err
+			}
+
+		case OutdentToken:
+			break tokenLoop
+
+		case CommentToken:
+			r.comment(token)
+
+		default:
+			continue
+		}
+	}
+
+	if err == io.EOF {
+		err = nil
+	}
+
+	return
+}
+
+func (r *documentReader) comment(token Token) {
+	info := token.LineInfo(0)
+	text := token.Text()
+
+	if r.last != nil && info.Lineno == r.last.end.Lineno {
+		r.last.LineComment = commentText(text)
+		return
+	}
+
+	if info.Lineno != r.docLineno+1 {
+		r.doc = nil
+	}
+
+	r.docLineno = info.Lineno
+	if !bytes.HasPrefix(text, []byte("#!")) && !bytes.HasPrefix(text, []byte("#@")) {
+		r.doc = append(r.doc, commentText(text))
+	}
+}
+
+// commentText strips the comment character
+// and up to one following space from a comment.
+func commentText(text []byte) string {
+	text = bytes.TrimPrefix(text[1:], []byte(" "))
+	return string(bytes.TrimRight(text, " \t"))
+}
+
+// Words returns the text of each argument that is not JSON.
+func (n *Node) Words() []string {
+	words := make([]string, 0, len(n.Args))
+	for _, arg := range n.Args {
+		if !arg.JSON {
+			words = append(words, arg.Text)
+		}
+	}
+
+	return words
+}
+
+// Object returns the source of the first JSON argument,
+// or nil if there is none.
+func (n *Node) Object() []byte {
+	for _, arg := range n.Args {
+		if arg.JSON {
+			return []byte(arg.Text)
+		}
+	}
+
+	return nil
+}
+
+// Objects returns the source of every JSON argument, in order.
+func (n *Node) Objects() [][]byte {
+	var objects [][]byte
+	for _, arg := range n.Args {
+		if arg.JSON {
+			objects = append(objects, []byte(arg.Text))
+		}
+	}
+
+	return objects
+}
+
+// String formats the directive as a single line of indentfile source,
+// without any of its children.
+func (n *Node) String() string {
+	var b strings.Builder
+	b.WriteString(quoteWord(n.Name))
+	for _, arg := range n.Args {
+		b.WriteByte(' ')
+		if arg.JSON {
+			b.WriteString(arg.Text)
+		} else {
+			b.WriteString(quoteWord(arg.Text))
+		}
+	}
+
+	return b.String()
+}
+
+// tokens recreates the tokens the directive was read from,
+// so that errors can be located in the same way as by ParseTokens.
+func (n *Node) tokens() []Token {
+	line := make([]Token, 0, len(n.Args)+2)
+	line = append(line, &nodeToken{WordToken, n.Name, n.Location})
+	for _, arg := range n.Args {
+		if arg.JSON {
+			line = append(line, &nodeToken{ObjectToken, arg.Text, arg.Location})
+		} else {
+			line = append(line, &nodeToken{WordToken, arg.Text, arg.Location})
+		}
+	}
+
+	end := n.end
+	if end.Lineno == 0 {
+		end = line[len(line)-1].LineInfo(0)
+	}
+
+	return append(line, &nodeToken{TerminatorToken, "\n", end})
+}
+
+func (n *Node) errorInFile(err error) error {
+	if n.File != "" {
+		err = ErrorInFile(err, n.File)
+	}
+
+	for use := n.ExpandedFrom; use != nil; use = use.ExpandedFrom {
+		err = errExpansion{err, use.File, use.Location}
+	}
+
+	return err
+}
+
+type nodeToken struct {
+	typ  TokenType
+	text string
+	info LineInfo
+}
+
+func (t *nodeToken) Type() TokenType {
+	return t.typ
+}
+
+func (t *nodeToken) LineInfo(at int) LineInfo {
+	return t.info
+}
+
+func (t *nodeToken) Text() []byte {
+	return []byte(t.text)
+}
+
+func quoteWord(word string) string {
+	if word != "" && !strings.ContainsAny(word, " \t\r\n#'\"") &&
+		word[0] != '{' && word[0] != '[' {
+		return word
+	}
+
+	// There are no escapes in the shell syntax,
+	// so double quotes are put inside single quotes instead.
+	return `"` + strings.ReplaceAll(word, `"`, `"'"'"`) + `"`
+}
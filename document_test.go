@@ -0,0 +1,105 @@
+package indentfile
+
+import (
+	"testing"
+)
+
+func TestReadDocument(t *testing.T) {
+	doc, err := ReadDocumentFile("test_files/document/servers.txt")
+	if err != nil {
+		t.Fatalf("ReadDocumentFile returned error: %v", err)
+	}
+
+	if len(doc.Children) != 3 {
+		t.Fatalf("Got %d top-level nodes; want 3", len(doc.Children))
+	}
+
+	api := doc.Children[0]
+	if api.Name != "server" || api.String() != "server api" {
+		t.Errorf("First node = %q; want %q", api.String(), "server api")
+	}
+
+	if !cmpLineInfo(api.Location, LineInfo{3, 1, nil}) {
+		t.Errorf("First node location = %v; want line 3:1", api.Location)
+	}
+
+	if len(api.Children) != 3 {
+		t.Fatalf("Got %d children of api; want 3", len(api.Children))
+	}
+
+	route := api.Children[2]
+	if words := route.Words(); len(words) != 1 || words[0] != "/v1" {
+		t.Errorf("route words = %q; want [/v1]", words)
+	}
+
+	if string(route.Object()) != `{"backend": "v1"}` {
+		t.Errorf("route object = %q", route.Object())
+	}
+
+	if !cmpLineInfo(route.Args[1].Location, LineInfo{6, 15, nil}) {
+		t.Errorf("route JSON location = %v; want line 6:15",
+			route.Args[1].Location)
+	}
+
+	internal := doc.Children[1]
+	if internal.String() != `server "api internal"` {
+		t.Errorf("Second node = %q; want %q",
+			internal.String(), `server "api internal"`)
+	}
+
+	if internal.Children[0].File != "test_files/document/servers.txt" {
+		t.Errorf("Nested node file = %q", internal.Children[0].File)
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	doc, err := ReadDocumentFile("test_files/document/comments.txt")
+	if err != nil {
+		t.Fatalf("ReadDocumentFile returned error: %v", err)
+	}
+
+	api := doc.Children[0]
+	listen := api.Children[0]
+	route := api.Children[1]
+	web := doc.Children[1]
+
+	cases := []struct {
+		what, actual, expect string
+	}{
+		{"api doc", api.Doc, "The API server.\n  Indented detail."},
+		{"api line comment", api.LineComment, "public"},
+		{"listen doc", listen.Doc, "Port to listen on."},
+		{"listen line comment", listen.LineComment, ""},
+		{"route doc", route.Doc, ""},
+		{"route line comment", route.LineComment, "the only route"},
+		{"web doc", web.Doc,
+			"Belongs to web, although less indented than the block above."},
+	}
+
+	for _, c := range cases {
+		if c.actual != c.expect {
+			t.Errorf("%s = %q; want %q", c.what, c.actual, c.expect)
+		}
+	}
+
+	if len(listen.Pragmas) != 1 {
+		t.Errorf("listen has %d pragmas; want 1", len(listen.Pragmas))
+	}
+}
+
+func TestQuoteWord(t *testing.T) {
+	cases := map[string]string{
+		"plain":      "plain",
+		"":           `""`,
+		"two words":  `"two words"`,
+		`say "hi"`:   `"say "'"'"hi"'"'""`,
+		"{not json}": `"{not json}"`,
+		"#hash":      `"#hash"`,
+	}
+
+	for word, expect := range cases {
+		if actual := quoteWord(word); actual != expect {
+			t.Errorf("quoteWord(%q) = %s; want %s", word, actual, expect)
+		}
+	}
+}
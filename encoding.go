@@ -0,0 +1,164 @@
+package indentfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// Column returns the 1-based column of the location in characters,
+// rather than in bytes as for Offset.
+func (l LineInfo) Column() int {
+	return l.column(func(r rune) int {
+		return 1
+	})
+}
+
+// UTF16Column returns the 1-based column of the location
+// in UTF-16 code units,
+// as used by editors following the Language Server Protocol.
+func (l LineInfo) UTF16Column() int {
+	return l.column(func(r rune) int {
+		if r >= 0x10000 {
+			return 2
+		}
+
+		return 1
+	})
+}
+
+func (l LineInfo) column(width func(r rune) int) int {
+	if l.Text == nil || l.Offset < 1 {
+		return l.Offset
+	}
+
+	// Locations just past the end of the line are still meaningful,
+	// such as a missing argument at the end of the last line.
+	end := l.Offset - 1
+	past := 0
+	if end > len(l.Text) {
+		past = end - len(l.Text)
+		end = len(l.Text)
+	}
+
+	column := 1 + past
+	for text := l.Text[:end]; len(text) > 0; {
+		r, size := utf8.DecodeRune(text)
+		column += width(r)
+		text = text[size:]
+	}
+
+	return column
+}
+
+// invalidUTF8 returns the index of the first byte in line
+// that is not valid UTF-8, or -1 if it is all valid.
+func invalidUTF8(line []byte) int {
+	if utf8.Valid(line) {
+		return -1
+	}
+
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRune(line[i:])
+		if r == utf8.RuneError && size == 1 {
+			return i
+		}
+
+		i += size
+	}
+
+	return -1
+}
+
+// utf16Reader converts UTF-16 input to UTF-8.
+// Unpaired surrogates and odd trailing bytes become invalid UTF-8,
+// so that the tokenizer reports them at the right location.
+type utf16Reader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+	out   []byte
+	// A code unit read while looking for a low surrogate.
+	next    uint16
+	hasNext bool
+}
+
+// newUTF16Reader returns a reader that decodes r as UTF-16
+// if it starts with a UTF-16 byte order mark,
+// or otherwise reads r unchanged.
+func newUTF16Reader(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	bom, _ := br.Peek(2)
+	if len(bom) < 2 {
+		return br
+	}
+
+	var order binary.ByteOrder
+	if bom[0] == 0xFE && bom[1] == 0xFF {
+		order = binary.BigEndian
+	} else if bom[0] == 0xFF && bom[1] == 0xFE {
+		order = binary.LittleEndian
+	} else {
+		return br
+	}
+
+	br.Discard(2)
+	return &utf16Reader{r: br, order: order}
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.out) == 0 {
+		unit, err := u.unit()
+		if err == io.ErrUnexpectedEOF {
+			u.out = append(u.out, 0xFF)
+			break
+		} else if err != nil {
+			return 0, err
+		}
+
+		r := rune(unit)
+		if utf16.IsSurrogate(r) {
+			low, err := u.unit()
+			if err == nil {
+				r = utf16.DecodeRune(r, rune(low))
+				if r == utf8.RuneError {
+					u.next = low
+					u.hasNext = true
+				}
+			} else {
+				r = utf8.RuneError
+			}
+
+			if r == utf8.RuneError {
+				u.out = append(u.out, 0xFF)
+				continue
+			}
+		}
+
+		var buf [utf8.UTFMax]byte
+		n := utf8.EncodeRune(buf[:], r)
+		u.out = append(u.out, buf[:n]...)
+	}
+
+	n := copy(p, u.out)
+	u.out = u.out[n:]
+	return n, nil
+}
+
+func (u *utf16Reader) unit() (uint16, error) {
+	if u.hasNext {
+		u.hasNext = false
+		return u.next, nil
+	}
+
+	var buf [2]byte
+	_, err := io.ReadFull(u.r, buf[:])
+	if err != nil {
+		return 0, err
+	}
+
+	return u.order.Uint16(buf[:]), nil
+}
@@ -0,0 +1,77 @@
+package indentfile
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestUnicodeColumns(t *testing.T) {
+	testTokenSequence(t, "tokens/unicode.txt", []expectToken{
+		{WordToken, LineInfo{1, 1, nil}, []byte("name"), nil},
+		{WordToken, LineInfo{1, 7, nil}, []byte("café"), nil},
+		{WordToken, LineInfo{1, 14, nil}, []byte("😀"), nil},
+		{WordToken, LineInfo{1, 19, nil}, []byte("x"), nil},
+		{TerminatorToken, LineInfo{1, 20, nil}, []byte{'\n'}, nil},
+	})
+
+	fd, err := os.Open("test_files/tokens/unicode.txt")
+	if err != nil {
+		panic(err)
+	}
+
+	defer fd.Close()
+
+	tok := NewTokenizer(fd)
+	expect := [][2]int{{1, 1}, {7, 7}, {13, 13}, {15, 16}}
+	for i, columns := range expect {
+		word, err := tok.Next()
+		if err != nil {
+			t.Fatalf("Next returned error: %v", err)
+		}
+
+		info := word.LineInfo(0)
+		if info.Column() != columns[0] || info.UTF16Column() != columns[1] {
+			t.Errorf("Word %d at columns %d, %d; want %d, %d", i,
+				info.Column(), info.UTF16Column(), columns[0], columns[1])
+		}
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	testTokenSequence(t, "tokens/invalid_utf8.txt", []expectToken{
+		{WordToken, LineInfo{1, 1, nil}, []byte("name"), nil},
+		{WordToken, LineInfo{1, 6, nil}, []byte("ok"), nil},
+		{TerminatorToken, LineInfo{1, 8, nil}, []byte{'\n'}, nil},
+		{nilToken, LineInfo{}, nil, ErrEncoding},
+	})
+
+	err := ParseFile("test_files/tokens/invalid_utf8.txt", HandlerFunc(
+		func(name string, argv []string) (interface{}, error) {
+			return nil, nil
+		}))
+	if !errors.Is(err, ErrEncoding) {
+		t.Fatalf("Got error %v; want %v", err, ErrEncoding)
+	} else if loc := ErrorLocation(err); loc.Lineno != 2 || loc.Offset != 11 {
+		t.Errorf("Got error at %d:%d; want 2:11", loc.Lineno, loc.Offset)
+	}
+}
+
+func TestUTF16(t *testing.T) {
+	fd, err := os.Open("test_files/tokens/utf16.txt")
+	if err != nil {
+		panic(err)
+	}
+
+	defer fd.Close()
+
+	tok := NewTokenizerWithOptions(fd, TokenizerOptions{DetectUTF16: true})
+	for _, expect := range []string{"name", "café", "😀"} {
+		word, err := tok.Next()
+		if err != nil {
+			t.Fatalf("Next returned error: %v", err)
+		} else if string(word.Text()) != expect {
+			t.Errorf("Got word %q; want %q", word.Text(), expect)
+		}
+	}
+}
@@ -16,11 +16,25 @@ var (
 	ErrOutdent     = errorWrap("unmatched indent", ErrToken)
 	ErrUnquote     = errorWrap("unclosed quotes", ErrToken)
 	ErrJSONBracket = errorWrap("unmatched JSON syntax", ErrToken)
+	ErrJSON        = errorWrap("invalid JSON", ErrToken)
+	ErrPragma      = errorWrap("bad pragma", ErrToken)
+	ErrEncoding    = errorWrap("bad encoding", ErrToken)
+
+	ErrLimit          = errorWrap("limit exceeded", ErrSyntax)
+	ErrLineTooLong    = errorWrap("line too long", ErrLimit)
+	ErrInputTooLarge  = errorWrap("input too large", ErrLimit)
+	ErrTooDeep        = errorWrap("blocks nested too deeply", ErrLimit)
+	ErrTooManyWords   = errorWrap("too many words in directive", ErrLimit)
+	ErrObjectTooLarge = errorWrap("JSON argument too large", ErrLimit)
+	ErrObjectTooDeep  = errorWrap("JSON argument nested too deeply", ErrLimit)
 
 	ErrDirective    = errorWrap("directive error", ErrSyntax)
 	ErrUnknown      = errorWrap("unknown directive", ErrDirective)
+	ErrDeprecated   = errorWrap("deprecated directive", ErrDirective)
 	ErrArguments    = errorWrap("bad argument", ErrDirective)
 	ErrArgumentJSON = errorWrap("unexpected JSON", ErrArguments)
+	ErrOverlay      = errorWrap("bad overlay directive", ErrDirective)
+	ErrMacro        = errorWrap("macro error", ErrDirective)
 )
 
 func ErrorLocation(err error) LineInfo {
@@ -33,17 +47,20 @@ func ErrorLocation(err error) LineInfo {
 
 func ErrorInFile(err error, filename string) error {
 	if locErr, is := err.(errWithLocation); is {
-		return errWithLocation{
-			Err:      locErr.Err,
-			Detail:   locErr.Detail,
-			File:     filename,
-			LineInfo: locErr.LineInfo,
-		}
+		locErr.File = filename
+		return locErr
 	}
 
 	return err
 }
 
+// ErrorAt returns err located at info,
+// such as a position found with the LineInfo method of a Token.
+// An error that already has a location is moved to info.
+func ErrorAt(err error, info LineInfo) error {
+	return errorAt(err, info)
+}
+
 func DirectiveErrorf(format string, v ...interface{}) error {
 	err := ErrDirective
 
@@ -123,15 +140,21 @@ type errWithLocation struct {
 	Detail    string
 	File      string
 	LineInfo
+	// Directive names suggested in place of the one in error.
+	Suggestions []string
+	// Edits that would fix the error.
+	Fixes []SuggestedFix
 }
 
 func errorAt(err error, info LineInfo) error {
 	if locErr, is := err.(errWithLocation); is {
 		return errWithLocation{
-			Err:      locErr.Err,
-			Detail:   locErr.Detail,
-			File:     locErr.File,
-			LineInfo: info,
+			Err:         locErr.Err,
+			Detail:      locErr.Detail,
+			File:        locErr.File,
+			LineInfo:    info,
+			Suggestions: locErr.Suggestions,
+			Fixes:       locErr.Fixes,
 		}
 	}
 
@@ -169,11 +192,15 @@ func (err errWithLocation) Error() string {
 
 	if err.File == "" {
 		return fmt.Sprintf("%s at line %d:%d%s",
-			err.Err.Error(), err.Lineno, err.Offset, detail)
+			err.Err.Error(), err.Lineno, err.Column(), detail)
 	}
 
 	return fmt.Sprintf("%s in file %s (%d:%d)%s",
-		err.Err.Error(), err.File, err.Lineno, err.Offset, detail)
+		err.Err.Error(), err.File, err.Lineno, err.Column(), detail)
+}
+
+func (err errWithLocation) Location() LineInfo {
+	return err.LineInfo
 }
 
 func (err errWithLocation) Unwrap() error {
@@ -184,6 +211,34 @@ func (err errWithLocation) Is(target error) bool {
 	return errors.Is(err.DetailErr, target)
 }
 
+func (err errWithLocation) As(target interface{}) bool {
+	return err.DetailErr != nil && errors.As(err.DetailErr, target)
+}
+
+type errExpansion struct {
+	Err  error
+	File string
+	LineInfo
+}
+
+func (err errExpansion) Error() string {
+	if err.File == "" {
+		return fmt.Sprintf("%s (expanded from line %d:%d)",
+			err.Err.Error(), err.Lineno, err.Column())
+	}
+
+	return fmt.Sprintf("%s (expanded from file %s (%d:%d))",
+		err.Err.Error(), err.File, err.Lineno, err.Column())
+}
+
+func (err errExpansion) Location() LineInfo {
+	return ErrorLocation(err.Err)
+}
+
+func (err errExpansion) Unwrap() error {
+	return err.Err
+}
+
 type errLocatable interface {
 	IntoLocation(tokens []Token) error
 }
@@ -192,6 +247,10 @@ type errorArg struct {
 	Err    error
 	Index  int
 	Detail string
+	// The offset of the error within the argument text.
+	At int
+	// Directive names suggested in place of the one in error.
+	Suggestions []string
 }
 
 func (err errorArg) Error() string {
@@ -228,10 +287,18 @@ func (err errorArg) IntoLocation(tokens []Token) error {
 		}
 	}
 
+	var fixes []SuggestedFix
+	if err.Index == 0 && len(err.Suggestions) > 0 {
+		fixes = renameFixes(tokens[0].LineInfo(0),
+			string(tokens[0].Text()), err.Suggestions)
+	}
+
 	return errWithLocation{
-		Err:       actualErr,
-		DetailErr: detailErr,
-		Detail:    err.Detail,
-		LineInfo:  problemToken.LineInfo(0),
+		Err:         actualErr,
+		DetailErr:   detailErr,
+		Detail:      err.Detail,
+		LineInfo:    problemToken.LineInfo(err.At),
+		Suggestions: err.Suggestions,
+		Fixes:       fixes,
 	}
 }
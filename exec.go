@@ -4,10 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
-	"os"
 	"reflect"
-	"strings"
-	"unicode"
 )
 
 type DirectiveHandler interface {
@@ -19,12 +16,20 @@ type ObjectDirectiveHandler interface {
 	ObjectDirective(name string, argv []string, json []byte) (interface{}, error)
 }
 
+// ArgsDirectiveHandler is the most general way to handle directives.
+// ArgsDirective receives every argument in source order,
+// so any number of JSON arguments can be mixed with words.
+type ArgsDirectiveHandler interface {
+	ArgsDirective(name string, args []Arg) (interface{}, error)
+}
+
 type EndDirectiveHandler interface {
 	End() error
 }
 
 type HandlerFunc func(name string, argv []string) (interface{}, error)
 type ObjectHandlerFunc func(name string, argv []string, json []byte) (interface{}, error)
+type ArgsHandlerFunc func(name string, args []Arg) (interface{}, error)
 
 func (fn HandlerFunc) Directive(name string, argv []string) (interface{}, error) {
 	return fn(name, argv)
@@ -38,80 +43,152 @@ func (fn ObjectHandlerFunc) ObjectDirective(name string, argv []string, json []b
 	return fn(name, argv, json)
 }
 
+func (fn ArgsHandlerFunc) ArgsDirective(name string, args []Arg) (interface{}, error) {
+	return fn(name, args)
+}
+
 func Parse(r io.Reader, context interface{}) error {
-	return ParseTokens(NewTokenizer(r), context)
+	return (&Parser{}).Parse(r, context)
 }
 
-func ParseFile(path string, context interface{}) (err error) {
-	var r io.ReadCloser
-	if path == "-" {
-		r = os.Stdin
-		path = "<stdin>"
-	} else {
-		r, err = os.Open(path)
-		if err != nil {
-			return
-		}
+func ParseFile(path string, context interface{}) error {
+	return (&Parser{}).ParseFile(path, context)
+}
 
-		defer r.Close()
-	}
+func ParseTokens(tok *Tokenizer, context interface{}) error {
+	return (&Parser{}).ParseTokens(tok, context)
+}
 
-	return ErrorInFile(Parse(r, context), path)
+// ParseTokens is like the ParseTokens function,
+// but follows the options of the parser.
+func (p *Parser) ParseTokens(tok *Tokenizer, context interface{}) error {
+	return p.traceError(p.parseTokens(tok, context, 0))
 }
 
-func ParseTokens(tok *Tokenizer, context interface{}) (err error) {
-	handler := getDirectiveHandlerFor(context)
+// parseTokens parses the block at the given depth.
+func (p *Parser) parseTokens(tok *Tokenizer, context interface{}, depth int) (err error) {
+	handler := p.handlerFor(context)
 	var token Token
 
 	var block interface{}
 	var line []Token
-	var words []string
-	var json []byte
+	var args []Arg
+	// The directive waiting to capture its block, if any.
+	var capture *pendingCapture
 
 tokenLoop:
 	for token, err = tok.Next(); err == nil; token, err = tok.Next() {
+		if p.Tracer != nil {
+			p.Tracer.Trace(TraceEvent{
+				Kind:     TraceToken,
+				Depth:    depth,
+				Location: token.LineInfo(0),
+				File:     p.file,
+				Token:    token,
+			})
+		}
+
 		switch token.Type() {
-		case WordToken:
-			line = append(line, token)
-			words = append(words, string(token.Text()))
+		case WordToken, ObjectToken:
+			if len(line) == 0 && capture != nil {
+				err = capture.run(nil)
+				capture = nil
+				if err != nil {
+					return
+				}
+			}
+
+			if len(line) > 0 {
+				args = append(args, Arg{
+					Text:     string(token.Text()),
+					JSON:     token.Type() == ObjectToken,
+					Location: token.LineInfo(0),
+				})
+			}
 
-		case ObjectToken:
 			line = append(line, token)
-			json = token.Text()
 
 		case TerminatorToken:
 			line = append(line, token)
-			if len(json) == 0 {
-				block, err = handler.Directive(words[0], words[1:])
-			} else {
-				block, err = handler.ObjectDirective(words[0], words[1:], json)
+			name := string(line[0].Text())
+			err = deliverPragmas(context, name, tok.TakePragmas())
+			if err != nil {
+				return locateError(err, line)
 			}
 
+			block, err = p.dispatch(depth, context, handler, line, name, args, nil)
 			if err != nil {
-				if locatable, is := err.(errLocatable); is {
-					err = locatable.IntoLocation(line)
-				} else if errors.Is(err, ErrArgumentJSON) {
-					err = errorAt(err, line[len(line)-2].LineInfo(0))
-				} else {
-					err = errorAt(err, line[0].LineInfo(0))
-				}
 				return
 			}
 
+			if fn, is := block.(CaptureFunc); is {
+				capture = &pendingCapture{fn, line}
+			}
+
 			line = nil
-			words = nil
-			json = nil
+			args = nil
 
 		case IndentToken:
-			if block == nil {
+			if capture != nil {
+				var children []*Node
+				children, err = readNodes(tok, p.file)
+				if err != nil {
+					return
+				}
+
+				err = capture.run(children)
+				capture = nil
+				if err != nil {
+					return
+				}
+
+				continue
+			} else if block == nil {
 				return errorAt(ErrIndent, token.LineInfo(0))
+			} else if raw, is := block.(RawBlockHandler); is {
+				var text Token
+				text, err = tok.NextRawBlock()
+				if err != nil {
+					return
+				}
+
+				if p.Tracer != nil {
+					p.Tracer.Trace(TraceEvent{
+						Kind:     TraceToken,
+						Depth:    depth + 1,
+						Location: text.LineInfo(0),
+						File:     p.file,
+						Token:    text,
+					})
+				}
+
+				err = raw.RawBlock(text)
+				if err != nil {
+					if ErrorLocation(err).Lineno == 0 {
+						err = errorAt(err, text.LineInfo(0))
+					}
+
+					return
+				}
+
+				continue
+			} else if custom, is := block.(TokenBlockHandler); is {
+				err = custom.ParseBlock(tok)
+				if err != nil {
+					return
+				}
+
+				continue
 			}
 
-			err = ParseTokens(tok, block)
+			p.traceBlock(TraceBlockEnter, depth+1, token.LineInfo(0), p.file, block)
+			err = p.parseTokens(tok, block, depth+1)
 			if err != nil {
 				return
 			}
 
+			p.traceBlock(TraceBlockExit, depth+1, LineInfo{}, p.file, block)
+
 		case OutdentToken:
 			break tokenLoop
 
@@ -126,22 +203,160 @@ tokenLoop:
 		return
 	}
 
+	if capture != nil {
+		err = capture.run(nil)
+		if err != nil {
+			return
+		}
+	}
+
+	if ender, is := context.(EndDirectiveHandler); is {
+		p.traceBlock(TraceEnd, depth, LineInfo{}, p.file, context)
+		err = ender.End()
+	}
+
+	return
+}
+
+// ParseNodes passes already-read directives to context,
+// following the same rules as ParseTokens.
+// Errors are reported at the location each node was read from.
+func ParseNodes(nodes []*Node, context interface{}) error {
+	return (&Parser{}).ParseNodes(nodes, context)
+}
+
+// ParseNodes is like the ParseNodes function,
+// but follows the options of the parser.
+func (p *Parser) ParseNodes(nodes []*Node, context interface{}) error {
+	return p.traceError(p.parseNodes(nodes, context, 0))
+}
+
+// parseNodes parses the block at the given depth.
+func (p *Parser) parseNodes(nodes []*Node, context interface{}, depth int) (err error) {
+	handler := p.handlerFor(context)
+
+	for _, node := range nodes {
+		err = deliverPragmas(context, node.Name, node.Pragmas)
+		if err != nil {
+			return node.errorInFile(locateError(err, node.tokens()))
+		}
+
+		var block interface{}
+		block, err = p.dispatch(depth, context, handler, node.tokens(), node.Name, node.Args, node)
+		if err != nil {
+			return node.errorInFile(err)
+		}
+
+		if fn, is := block.(CaptureFunc); is {
+			capture := &pendingCapture{fn, node.tokens()}
+			err = capture.run(node.Children)
+			if err != nil {
+				return node.errorInFile(err)
+			}
+
+			continue
+		}
+
+		if len(node.Children) == 0 {
+			continue
+		} else if block == nil {
+			child := node.Children[0]
+			return child.errorInFile(errorAt(ErrIndent, child.Location))
+		}
+
+		child := node.Children[0]
+		p.traceBlock(TraceBlockEnter, depth+1, child.Location, child.File, block)
+		err = p.parseNodes(node.Children, block, depth+1)
+		if err != nil {
+			return
+		}
+
+		p.traceBlock(TraceBlockExit, depth+1, LineInfo{}, child.File, block)
+	}
+
 	if ender, is := context.(EndDirectiveHandler); is {
+		file := ""
+		if len(nodes) > 0 {
+			file = nodes[0].File
+		}
+
+		p.traceBlock(TraceEnd, depth, LineInfo{}, file, context)
 		err = ender.End()
 	}
 
 	return
 }
 
-func getDirectiveHandlerFor(context interface{}) ObjectDirectiveHandler {
-	if handler, is := context.(ObjectDirectiveHandler); is {
+// ParseDocument passes every directive in doc to context,
+// following the same rules as ParseTokens.
+func ParseDocument(doc *Document, context interface{}) error {
+	return (&Parser{}).ParseDocument(doc, context)
+}
+
+func locateError(err error, line []Token) error {
+	if locatable, is := err.(errLocatable); is {
+		return locatable.IntoLocation(line)
+	} else if errors.Is(err, ErrArgumentJSON) {
+		for i := len(line) - 1; i > 0; i-- {
+			if line[i].Type() == ObjectToken {
+				return errorAt(err, line[i].LineInfo(0))
+			}
+		}
+	}
+
+	return errorAt(err, line[0].LineInfo(0))
+}
+
+// namedHandler is implemented by handlers that pass directives on
+// to contexts of their own, such as the one returned by WithConditions,
+// so that the reflection API maps names for those contexts
+// as it does for any other.
+type namedHandler interface {
+	withNames(names *NameMapping) ArgsDirectiveHandler
+}
+
+// getDirectiveHandlerFor adapts any context to ArgsDirectiveHandler,
+// mapping directive names to methods with names if it is not nil.
+func getDirectiveHandlerFor(context interface{}, names *NameMapping) ArgsDirectiveHandler {
+	if handler, is := context.(namedHandler); is {
+		return handler.withNames(names)
+	} else if handler, is := context.(ArgsDirectiveHandler); is {
 		return handler
+	} else if handler, is := context.(ObjectDirectiveHandler); is {
+		return objectHandler{handler}
 	} else if handler, is := context.(DirectiveHandler); is {
-		return &patchedHandler{handler}
+		return objectHandler{&patchedHandler{handler}}
+	}
+
+	if names == nil {
+		names = defaultNames
 	}
 
-	valueOf := reflect.ValueOf(context)
-	return methodDirectiveHandler(valueOf)
+	return methodDirectiveHandler{reflect.ValueOf(context), names}
+}
+
+// objectHandler adapts an ObjectDirectiveHandler,
+// which can only accept one JSON argument.
+type objectHandler struct {
+	ObjectDirectiveHandler
+}
+
+func (h objectHandler) ArgsDirective(name string, args []Arg) (interface{}, error) {
+	words, objects, _, objIndexes := splitArgs(args)
+	if len(objects) == 0 {
+		return h.Directive(name, words)
+	}
+
+	if len(objects) > 1 {
+		return nil, ArgumentErrorf(objIndexes[1], "%w", ErrArgumentJSON)
+	}
+
+	_, isPatched := h.ObjectDirectiveHandler.(*patchedHandler)
+	if isPatched {
+		return nil, ArgumentErrorf(objIndexes[0], "%w", ErrArgumentJSON)
+	}
+
+	return h.ObjectDirective(name, words, objects[0])
 }
 
 type patchedHandler struct {
@@ -152,31 +367,72 @@ func (h *patchedHandler) ObjectDirective(name string, argv []string, object []by
 	return nil, ErrArgumentJSON
 }
 
-type methodDirectiveHandler reflect.Value
+// splitArgs separates the words and JSON arguments in args,
+// also returning the index in args of each.
+func splitArgs(args []Arg) (words []string, objects [][]byte, wordIndexes, objIndexes []int) {
+	words = make([]string, 0, len(args))
+	for i, arg := range args {
+		if arg.JSON {
+			objects = append(objects, []byte(arg.Text))
+			objIndexes = append(objIndexes, i)
+		} else {
+			words = append(words, arg.Text)
+			wordIndexes = append(wordIndexes, i)
+		}
+	}
+
+	return
+}
+
+// joinArgs builds the arguments for ArgsDirective
+// from those given to ObjectDirective.
+func joinArgs(argv []string, json []byte) []Arg {
+	args := make([]Arg, len(argv), len(argv)+1)
+	for i, word := range argv {
+		args[i] = Arg{Text: word}
+	}
 
-func (ctx methodDirectiveHandler) Directive(name string, argv []string) (interface{}, error) {
-	return ctx.ObjectDirective(name, argv, nil)
+	if json != nil {
+		args = append(args, Arg{Text: string(json), JSON: true})
+	}
+
+	return args
 }
 
-func (ctx methodDirectiveHandler) ObjectDirective(name string, argv []string, object []byte) (interface{}, error) {
-	if strings.ToLower(name) != name {
-		return nil, DirectiveErrorf("%w %q", ErrUnknown, name)
+// objectError reports an error decoding the JSON argument at index,
+// at the position of any syntax error within it.
+func objectError(index int, err error) error {
+	argErr := ArgumentErrorf(index, "%w", err).(errorArg)
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		argErr.At = int(syntaxErr.Offset) - 1
 	}
 
-	methodName := snakeToPascal(name)
+	return argErr
+}
 
-	method := reflect.Value(ctx).MethodByName(methodName)
+type methodDirectiveHandler struct {
+	value reflect.Value
+	names *NameMapping
+}
+
+func (ctx methodDirectiveHandler) ArgsDirective(name string, args []Arg) (interface{}, error) {
+	methodName, method := ctx.names.method(ctx.value, ctx.resolveAlias(name))
 	if !method.IsValid() {
-		return nil, DirectiveErrorf("%w %q", ErrUnknown, name)
+		return nil, ctx.unknown(name)
 	}
 
 	methodType := method.Type()
 	nargs := methodType.NumIn()
 	nret := methodType.NumOut()
-	var argValues, results []reflect.Value
+	var argValues []reflect.Value
 
 	if nret > 2 {
-		return nil, DirectiveErrorf("%w %q", ErrUnknown, name)
+		return nil, ctx.unknown(name)
+	} else if reservedMethods[methodName] {
+		return nil, DirectiveErrorf("%w %q (.%s is a reserved method)",
+			ErrUnknown, name, methodName)
 	} else if methodName == "End" && nargs == 0 && nret == 1 {
 		if methodType.Out(0).Implements(
 			reflect.TypeOf((*error)(nil)).Elem()) {
@@ -185,12 +441,6 @@ func (ctx methodDirectiveHandler) ObjectDirective(name string, argv []string, ob
 		}
 	}
 
-	nargv := len(argv)
-	objIndex := -1
-	if object == nil {
-		objIndex = -2
-	}
-
 	if methodType.IsVariadic() {
 		nargs--
 		if methodType.In(nargs).Elem().Kind() != reflect.String {
@@ -199,68 +449,100 @@ func (ctx methodDirectiveHandler) ObjectDirective(name string, argv []string, ob
 		}
 	}
 
+	// Each string parameter takes the next word,
+	// a Block parameter takes the block of the directive,
+	// and each other parameter takes the next JSON argument.
+	nwords, nobjects := 0, 0
+	blockIndex := -1
 	for i := 0; i < nargs; i++ {
-		argType := methodType.In(i)
-		if argType.Kind() != reflect.String {
-			if objIndex == -1 {
-				objIndex = i
-			} else {
-				return nil, DirectiveErrorf("%w %q (.%s has bad signature)",
-					ErrUnknown, name, methodName)
-			}
+		if methodType.In(i) == blockType && blockIndex < 0 {
+			blockIndex = i
+		} else if methodType.In(i).Kind() == reflect.String {
+			nwords++
+		} else {
+			nobjects++
 		}
 	}
 
-	if object != nil {
-		nargs--
+	words, objects, wordIndexes, objIndexes := splitArgs(args)
+
+	if len(objects) > nobjects {
+		return nil, ArgumentErrorf(objIndexes[nobjects], "%w", ErrArgumentJSON)
 	}
 
-	if nargv < nargs {
-		return nil, ArgumentErrorf(nargv+3, "not enough arguments")
+	if len(words) < nwords {
+		return nil, ArgumentErrorf(len(args), "not enough arguments (want %s)",
+			directiveSignature(name, methodType))
 	}
 
-	if !methodType.IsVariadic() && nargv > nargs {
-		return nil, ArgumentErrorf(nargs, "too many arguments")
+	if !methodType.IsVariadic() && len(words) > nwords {
+		return nil, ArgumentErrorf(wordIndexes[nwords], "too many arguments (want %s)",
+			directiveSignature(name, methodType))
 	}
 
-	if objIndex == -1 {
-		return nil, ArgumentErrorf(-1, "expected JSON argument")
+	if len(objects) < nobjects {
+		return nil, ArgumentErrorf(-1, "expected JSON argument (want %s)",
+			directiveSignature(name, methodType))
 	}
 
-	argValues = make([]reflect.Value, nargv)
-	if object != nil {
-		objArgType := methodType.In(objIndex)
+	argValues = make([]reflect.Value, 0, nargs+len(words)-nwords)
+	for i := 0; i < nargs; i++ {
+		argType := methodType.In(i)
+		if i == blockIndex {
+			argValues = append(argValues, reflect.Zero(blockType))
+			continue
+		} else if argType.Kind() == reflect.String {
+			argValues = append(argValues, reflect.ValueOf(words[0]))
+			words = words[1:]
+			continue
+		}
+
 		unPtr := false
-		if objArgType.Kind() == reflect.Ptr {
-			objArgType = objArgType.Elem()
+		if argType.Kind() == reflect.Ptr {
+			argType = argType.Elem()
 		} else {
 			unPtr = true
 		}
-		objArgValue := reflect.New(objArgType)
-		err := json.Unmarshal(object, objArgValue.Interface())
+
+		objArgValue := reflect.New(argType)
+		err := json.Unmarshal(objects[0], objArgValue.Interface())
 		if err != nil {
-			return nil, ArgumentErrorf(-1, "%w", err)
+			return nil, objectError(objIndexes[0], err)
 		}
 
-		argValues = append(argValues, reflect.Value{})
 		if unPtr {
-			argValues[objIndex] = objArgValue.Elem()
-		} else {
-			argValues[objIndex] = objArgValue
+			objArgValue = objArgValue.Elem()
 		}
-	} else {
-		objIndex = nargv
+
+		argValues = append(argValues, objArgValue)
+		objects = objects[1:]
+		objIndexes = objIndexes[1:]
 	}
 
-	for i, arg := range argv {
-		if i >= objIndex {
-			i++
-		}
+	for _, word := range words {
+		argValues = append(argValues, reflect.ValueOf(word))
+	}
 
-		argValues[i] = reflect.ValueOf(arg)
+	if blockIndex >= 0 {
+		return CaptureFunc(func(block Block) error {
+			argValues[blockIndex] = reflect.ValueOf(block)
+			_, err := callMethod(method, argValues)
+			return err
+		}), nil
 	}
 
-	results = method.Call(argValues)
+	return callMethod(method, argValues)
+}
+
+// unknown reports that the context has no directive called name.
+func (ctx methodDirectiveHandler) unknown(name string) error {
+	return unknownError(name, directiveNames(ctx.value.Type(), ctx.names))
+}
+
+// callMethod calls a directive method,
+// interpreting its results as described in the package documentation.
+func callMethod(method reflect.Value, argValues []reflect.Value) (interface{}, error) {
+	results := method.Call(argValues)
 
 	if len(results) == 1 {
 		result := results[0].Interface()
@@ -280,34 +562,3 @@ func (ctx methodDirectiveHandler) ObjectDirective(name string, argv []string, ob
 		return nil, nil
 	}
 }
-
-func snakeToPascal(name string) string {
-	isFirstOfWord := true
-	isFirstOfName := true
-	needPrepend := false
-	name = strings.Map(func(c rune) rune {
-		if c == '-' {
-			isFirstOfWord = true
-			return -1
-		}
-
-		if isFirstOfWord {
-			k := unicode.ToUpper(c)
-			if k == c && isFirstOfName {
-				needPrepend = true
-			}
-
-			isFirstOfWord = false
-			isFirstOfName = false
-			return k
-		}
-
-		return c
-	}, name)
-
-	if needPrepend {
-		return "X" + name
-	}
-
-	return name
-}
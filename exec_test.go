@@ -2,6 +2,7 @@ package indentfile
 
 import (
 	"container/list"
+	"errors"
 	"strings"
 	"testing"
 )
@@ -72,6 +73,123 @@ func TestParseJSON(t *testing.T) {
 	}
 }
 
+func TestParseMultipleJSON(t *testing.T) {
+	messages := list.New()
+	ctx := &msgCtx{messages, ""}
+
+	err := ParseFile("test_files/parse/multi_json.txt", ctx)
+	if err != nil {
+		t.Fatalf("ParseFile returned error: %v", err)
+	}
+
+	expect := []string{
+		"many a and [b]",
+		"many *c* or d",
+		"<many end>",
+		"<end>",
+	}
+
+	if len(expect) != messages.Len() {
+		t.Fatalf("Got %d messages; want %d", messages.Len(), len(expect))
+	}
+
+	node := messages.Front()
+	for i, msg := range expect {
+		val := node.Value.(string)
+		if val != msg {
+			t.Fatalf("Got %d = %q; want %q", i, val, msg)
+		}
+
+		node = node.Next()
+	}
+}
+
+func TestParseTooManyJSON(t *testing.T) {
+	handler := ObjectHandlerFunc(func(name string, argv []string, json []byte) (interface{}, error) {
+		return nil, nil
+	})
+
+	err := Parse(strings.NewReader("one {} two\n"), handler)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	err = Parse(strings.NewReader("one {} two\n"), HandlerFunc(
+		func(name string, argv []string) (interface{}, error) {
+			return nil, nil
+		}))
+	if !errors.Is(err, ErrArgumentJSON) {
+		t.Fatalf("Got error %v; want %v", err, ErrArgumentJSON)
+	} else if loc := ErrorLocation(err); loc.Lineno != 1 || loc.Offset != 5 {
+		t.Fatalf("Got error at %d:%d; want 1:5", loc.Lineno, loc.Offset)
+	}
+
+	err = Parse(strings.NewReader("one {} two [] {}\n"), handler)
+	if !errors.Is(err, ErrArgumentJSON) {
+		t.Fatalf("Got error %v; want %v", err, ErrArgumentJSON)
+	} else if loc := ErrorLocation(err); loc.Lineno != 1 || loc.Offset != 12 {
+		t.Fatalf("Got error at %d:%d; want 1:12", loc.Lineno, loc.Offset)
+	}
+}
+
+func TestParseNestedOutdent(t *testing.T) {
+	messages := list.New()
+	ctx := &msgCtx{messages, ""}
+
+	err := Parse(strings.NewReader(
+		"prefix a\n  prefix b\n    msg deep\nmsg shallow\n"), ctx)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	expect := []string{"a b deep", "<a b end>", "<a end>", "shallow", "<end>"}
+	node := messages.Front()
+	for i, msg := range expect {
+		if node == nil || node.Value.(string) != msg {
+			t.Fatalf("Got %d = %v; want %q", i, node, msg)
+		}
+
+		node = node.Next()
+	}
+}
+
+func TestParseDocument(t *testing.T) {
+	doc, err := ReadDocumentFile("test_files/parse/simple.txt")
+	if err != nil {
+		t.Fatalf("ReadDocumentFile returned error: %v", err)
+	}
+
+	fromTokens := list.New()
+	err = ParseFile("test_files/parse/simple.txt", &msgCtx{fromTokens, ""})
+	if err != nil {
+		t.Fatalf("ParseFile returned error: %v", err)
+	}
+
+	fromNodes := list.New()
+	err = ParseDocument(doc, &msgCtx{fromNodes, ""})
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %v", err)
+	}
+
+	if fromNodes.Len() != fromTokens.Len() {
+		t.Fatalf("Got %d messages; want %d", fromNodes.Len(), fromTokens.Len())
+	}
+
+	for a, b := fromNodes.Front(), fromTokens.Front(); a != nil; a, b = a.Next(), b.Next() {
+		if a.Value != b.Value {
+			t.Fatalf("Got %q; want %q", a.Value, b.Value)
+		}
+	}
+}
+
+// anyCtx accepts every directive,
+// and is also the context for every block.
+type anyCtx struct{}
+
+func (ctx anyCtx) ArgsDirective(name string, args []Arg) (interface{}, error) {
+	return ctx, nil
+}
+
 type msgCtx struct {
 	messages *list.List
 	prefix   string
@@ -98,13 +216,11 @@ func (m msgCtx) Prefix(words ...string) msgCtx {
 }
 
 func (m msgCtx) Object(obj msgObject) {
-	if obj.Surround == "" {
-		m.Msg(obj.Text)
-	} else if len(obj.Surround) == 2 {
-		m.Msg(obj.Surround[:1] + obj.Text + obj.Surround[1:])
-	} else {
-		m.Msg(obj.Surround + obj.Text + obj.Surround)
-	}
+	m.Msg(obj.String())
+}
+
+func (m msgCtx) Wrap(first msgObject, word string, second *msgObject) {
+	m.Msg(first.String(), word, second.String())
 }
 
 func (m msgCtx) End() error {
@@ -121,3 +237,13 @@ type msgObject struct {
 	Text     string `json:"text"`
 	Surround string `json:"sur,omitempty"`
 }
+
+func (obj msgObject) String() string {
+	if obj.Surround == "" {
+		return obj.Text
+	} else if len(obj.Surround) == 2 {
+		return obj.Surround[:1] + obj.Text + obj.Surround[1:]
+	} else {
+		return obj.Surround + obj.Text + obj.Surround
+	}
+}
@@ -0,0 +1,199 @@
+package indentfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// TextEdit replaces a range of source text.
+// Only the Lineno and Offset of Start and End are used,
+// and End is the location just after the replaced text,
+// so an edit with Start equal to End inserts NewText.
+type TextEdit struct {
+	Start   LineInfo
+	End     LineInfo
+	NewText string
+}
+
+// SuggestedFix is a set of edits that would fix an error,
+// such as one reported by the Tokenizer.
+// Message describes the fix for the user.
+type SuggestedFix struct {
+	Message string
+	Edits   []TextEdit
+}
+
+// ErrorFixes returns the fixes suggested by err,
+// most likely first,
+// or nil if it suggests none.
+// At most one of the fixes should be applied.
+func ErrorFixes(err error) []SuggestedFix {
+	var fixer interface {
+		fixes() []SuggestedFix
+	}
+
+	if errors.As(err, &fixer) {
+		return fixer.fixes()
+	}
+
+	return nil
+}
+
+func (err errWithLocation) fixes() []SuggestedFix {
+	return err.Fixes
+}
+
+// ApplyFixes applies every edit of fixes to src,
+// which must be the source the fixes were suggested for,
+// and returns the result.
+// It is an error for edits to overlap or to be outside src.
+func ApplyFixes(src []byte, fixes []SuggestedFix) ([]byte, error) {
+	// Locations on the first line are after any byte order mark.
+	bom := 0
+	if bytes.HasPrefix(src, utf8BOM) {
+		bom = len(utf8BOM)
+	}
+
+	lineStarts := []int{0}
+	for i, c := range src {
+		if c == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+
+	position := func(info LineInfo) (int, error) {
+		if info.Lineno < 1 || info.Lineno > len(lineStarts) || info.Offset < 1 {
+			return 0, fmt.Errorf("edit location %d:%d not in source",
+				info.Lineno, info.Offset)
+		}
+
+		pos := lineStarts[info.Lineno-1] + info.Offset - 1
+		if info.Lineno == 1 {
+			pos += bom
+		}
+
+		if pos > len(src) {
+			return 0, fmt.Errorf("edit location %d:%d not in source",
+				info.Lineno, info.Offset)
+		}
+
+		return pos, nil
+	}
+
+	type edit struct {
+		start, end int
+		text       string
+	}
+
+	var edits []edit
+	for _, fix := range fixes {
+		for _, e := range fix.Edits {
+			start, err := position(e.Start)
+			if err != nil {
+				return nil, err
+			}
+
+			end, err := position(e.End)
+			if err != nil {
+				return nil, err
+			} else if end < start {
+				return nil, fmt.Errorf("edit at %d:%d ends before it starts",
+					e.Start.Lineno, e.Start.Offset)
+			}
+
+			edits = append(edits, edit{start, end, e.NewText})
+		}
+	}
+
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].start < edits[j].start
+	})
+
+	var out bytes.Buffer
+	last := 0
+	for _, e := range edits {
+		if e.start < last {
+			return nil, fmt.Errorf("overlapping edits")
+		}
+
+		out.Write(src[last:e.start])
+		out.WriteString(e.text)
+		last = e.end
+	}
+
+	out.Write(src[last:])
+	return out.Bytes(), nil
+}
+
+// withFixes attaches fixes to a located error.
+func withFixes(err error, fixes ...SuggestedFix) error {
+	if locErr, is := err.(errWithLocation); is {
+		locErr.Fixes = append(locErr.Fixes, fixes...)
+		return locErr
+	}
+
+	return err
+}
+
+// crFix replaces the lone CR at info with a line ending,
+// matching the ending of the line it is on if it has one.
+func crFix(info LineInfo) SuggestedFix {
+	ending := "\n"
+	if bytes.HasSuffix(info.Text, []byte("\r\n")) {
+		ending = "\r\n"
+	}
+
+	end := info
+	end.Offset++
+	return SuggestedFix{
+		Message: "replace CR with a line ending",
+		Edits:   []TextEdit{{info, end, ending}},
+	}
+}
+
+// unquoteFix closes a quote left open at the end of a line,
+// where info is the location of the line ending.
+func unquoteFix(info LineInfo, quote byte) SuggestedFix {
+	return SuggestedFix{
+		Message: fmt.Sprintf("close quote with %c", quote),
+		Edits:   []TextEdit{{info, info, string(quote)}},
+	}
+}
+
+// reindentFix replaces the indent of a line,
+// which ends just before info,
+// with indent.
+func reindentFix(info LineInfo, indent []byte) SuggestedFix {
+	start := info
+	start.Offset = 1
+	return SuggestedFix{
+		Message: fmt.Sprintf("re-indent line with %q", indent),
+		Edits:   []TextEdit{{start, info, string(indent)}},
+	}
+}
+
+// renameFixes suggests replacing the directive name at info
+// with each of names.
+func renameFixes(info LineInfo, name string, names []string) []SuggestedFix {
+	if info.Offset < 1 || info.Offset > len(info.Text) ||
+		!bytes.HasPrefix(info.Text[info.Offset-1:], []byte(name)) {
+		// The name isn't written plainly in the source,
+		// so it can't be replaced reliably.
+		return nil
+	}
+
+	end := info
+	end.Offset += len(name)
+
+	var fixes []SuggestedFix
+	for _, suggestion := range names {
+		fixes = append(fixes, SuggestedFix{
+			Message: fmt.Sprintf("rename to %q", suggestion),
+			Edits:   []TextEdit{{info, end, suggestion}},
+		})
+	}
+
+	return fixes
+}
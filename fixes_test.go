@@ -0,0 +1,68 @@
+package indentfile
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestErrorFixes(t *testing.T) {
+	tests := []struct {
+		source string
+		err    error
+		fixed  string
+	}{
+		{"a b\rc d\n", ErrCRLF, "a b\nc d\n"},
+		{"a b\rc d\r\n", ErrCRLF, "a b\r\nc d\r\n"},
+		{"a \"b c\n", ErrUnquote, "a \"b c\"\n"},
+		{"a\n\tb\n  c\n", ErrOutdent, "a\n\tb\n\tc\n"},
+		{"a\n    b\n        c\n      d\n", ErrOutdent, "a\n    b\n        c\n    d\n"},
+		{"\xEF\xBB\xBFa \"b\n", ErrUnquote, "\xEF\xBB\xBFa \"b\"\n"},
+	}
+
+	for _, test := range tests {
+		_, err := ReadDocument(strings.NewReader(test.source))
+		if !errors.Is(err, test.err) {
+			t.Errorf("ReadDocument(%q) error = %v; want %v", test.source, err, test.err)
+			continue
+		}
+
+		fixes := ErrorFixes(err)
+		if len(fixes) != 1 {
+			t.Errorf("ReadDocument(%q) got %d fixes; want 1", test.source, len(fixes))
+			continue
+		}
+
+		fixed, err := ApplyFixes([]byte(test.source), fixes)
+		if err != nil {
+			t.Errorf("ApplyFixes(%q) returned error: %v", test.source, err)
+		} else if string(fixed) != test.fixed {
+			t.Errorf("ApplyFixes(%q) = %q; want %q", test.source, fixed, test.fixed)
+		} else if _, err = ReadDocument(bytes.NewReader(fixed)); err != nil {
+			t.Errorf("Fixed %q has error: %v", fixed, err)
+		}
+	}
+
+	source := "log debug\nlistne :80\n"
+	err := Parse(strings.NewReader(source), serverCtx{})
+	fixes := ErrorFixes(err)
+	if len(fixes) != 1 || fixes[0].Message != `rename to "listen"` {
+		t.Fatalf("Parse(%q) got fixes %v", source, fixes)
+	}
+
+	fixed, err := ApplyFixes([]byte(source), fixes)
+	if err != nil {
+		t.Fatalf("ApplyFixes returned error: %v", err)
+	} else if string(fixed) != "log debug\nlisten :80\n" {
+		t.Errorf("ApplyFixes = %q", fixed)
+	}
+
+	_, err = ApplyFixes([]byte(source), []SuggestedFix{{Edits: []TextEdit{
+		{LineInfo{Lineno: 1, Offset: 1}, LineInfo{Lineno: 1, Offset: 4}, "x"},
+		{LineInfo{Lineno: 1, Offset: 2}, LineInfo{Lineno: 1, Offset: 2}, "y"},
+	}}})
+	if err == nil {
+		t.Errorf("ApplyFixes with overlapping edits returned no error")
+	}
+}
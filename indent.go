@@ -0,0 +1,75 @@
+package indentfile
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// IndentChars selects the characters an IndentPolicy allows.
+type IndentChars int
+
+// The valid IndentChars values.
+const (
+	// Any mix of tabs and spaces may be used.
+	AnyIndent = IndentChars(iota)
+	// Only tabs may be used.
+	TabIndent
+	// Only spaces may be used.
+	SpaceIndent
+)
+
+// IndentPolicy restricts the indentation of new blocks.
+// The zero IndentPolicy allows any indentation,
+// as long as each block is consistent with the block containing it.
+//
+// A policy with Consistent set remembers the first indent it checks,
+// so each file should be checked with its own copy of the policy.
+type IndentPolicy struct {
+	// The characters that may be used to indent a block.
+	Chars IndentChars
+	// If non-zero, the number of characters
+	// each block must be indented by
+	// relative to the block containing it.
+	Width int
+	// Whether every block must be indented
+	// by the same characters as the first indented block in the file.
+	Consistent bool
+
+	unit []byte
+}
+
+// Check reports whether a block indented by indent,
+// within a block indented by parent,
+// follows the policy.
+// The returned error describes the problem,
+// and should be located at the end of parent.
+func (p *IndentPolicy) Check(parent, indent []byte) error {
+	unit := indent[len(parent):]
+
+	switch p.Chars {
+	case TabIndent:
+		if bytes.IndexByte(unit, ' ') >= 0 {
+			return fmt.Errorf("indented with spaces; want tabs")
+		}
+
+	case SpaceIndent:
+		if bytes.IndexByte(unit, '\t') >= 0 {
+			return fmt.Errorf("indented with tabs; want spaces")
+		}
+	}
+
+	if p.Width > 0 && len(unit) != p.Width {
+		return fmt.Errorf("indented by %d; want %d", len(unit), p.Width)
+	}
+
+	if p.Consistent {
+		if p.unit == nil {
+			p.unit = append([]byte{}, unit...)
+		} else if !bytes.Equal(unit, p.unit) {
+			return fmt.Errorf("indented by %q; want %q as in the first block",
+				unit, p.unit)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package indentfile
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestIndentPolicy(t *testing.T) {
+	tests := []struct {
+		policy IndentPolicy
+		src    string
+		// The location of the expected error, or 0 for none.
+		lineno, offset int
+	}{
+		{IndentPolicy{}, "a\n   b\n   \tc\nd\n", 0, 0},
+		{IndentPolicy{Chars: TabIndent}, "a\n\tb\n\t\tc\n", 0, 0},
+		{IndentPolicy{Chars: TabIndent}, "a\n\tb\n\t  c\n", 3, 2},
+		{IndentPolicy{Chars: SpaceIndent}, "a\n \tb\n", 2, 1},
+		{IndentPolicy{Width: 4}, "a\n    b\n       c\n", 3, 5},
+		{IndentPolicy{Consistent: true}, "a\n  b\n    c\nd\n  e\n", 0, 0},
+		{IndentPolicy{Consistent: true}, "a\n  b\nc\n\td\n", 4, 1},
+	}
+
+	for i, test := range tests {
+		tok := NewTokenizerWithOptions(strings.NewReader(test.src),
+			TokenizerOptions{Indent: test.policy})
+		err := ParseTokens(tok, anyCtx{})
+
+		if test.lineno == 0 {
+			if err != nil {
+				t.Errorf("Test %d returned error: %v", i, err)
+			}
+		} else if !errors.Is(err, ErrIndent) {
+			t.Errorf("Test %d got error %v; want %v", i, err, ErrIndent)
+		} else if loc := ErrorLocation(err); loc.Lineno != test.lineno || loc.Offset != test.offset {
+			t.Errorf("Test %d got error at %d:%d; want %d:%d", i,
+				loc.Lineno, loc.Offset, test.lineno, test.offset)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package indentfile
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		opts           TokenizerOptions
+		src            string
+		err            error
+		lineno, offset int
+	}{
+		{TokenizerOptions{MaxLineLength: 8}, "a 345678\r\nb\n", nil, 0, 0},
+		{TokenizerOptions{MaxLineLength: 8}, "a\nb 3456789\n", ErrLineTooLong, 2, 9},
+		{TokenizerOptions{MaxLineLength: 4}, "a\n" + strings.Repeat("b", 10000), ErrLineTooLong, 2, 5},
+		{TokenizerOptions{MaxInputSize: 8}, "a\nb\nc\nd\n", nil, 0, 0},
+		{TokenizerOptions{MaxInputSize: 8}, "a\nb\nc\nd e\n", ErrInputTooLarge, 4, 3},
+		{TokenizerOptions{MaxDepth: 2}, "a\n b\n  c\n", nil, 0, 0},
+		{TokenizerOptions{MaxDepth: 2}, "a\n b\n  c\n   d\n", ErrTooDeep, 4, 4},
+		{TokenizerOptions{MaxWords: 3}, "a b c\nd {} []\n", nil, 0, 0},
+		{TokenizerOptions{MaxWords: 3}, "a b c\nd {} [] e\n", ErrTooManyWords, 2, 9},
+		{TokenizerOptions{MaxObjectSize: 6}, "a {\n}\nb [1,2]\n", nil, 0, 0},
+		{TokenizerOptions{MaxObjectSize: 6}, "a [1,2,3]\n", ErrObjectTooLarge, 1, 9},
+		{TokenizerOptions{MaxObjectDepth: 2}, `a [{"b": "[["}]` + "\n", nil, 0, 0},
+		{TokenizerOptions{MaxObjectDepth: 2}, "a [[[]]]\n", ErrObjectTooDeep, 1, 5},
+	}
+
+	for i, test := range tests {
+		tok := NewTokenizerWithOptions(strings.NewReader(test.src), test.opts)
+		err := ParseTokens(tok, anyCtx{})
+
+		if test.err == nil {
+			if err != nil {
+				t.Errorf("Test %d returned error: %v", i, err)
+			}
+		} else if !errors.Is(err, test.err) || !errors.Is(err, ErrSyntax) {
+			t.Errorf("Test %d got error %v; want %v", i, err, test.err)
+		} else if loc := ErrorLocation(err); loc.Lineno != test.lineno || loc.Offset != test.offset {
+			t.Errorf("Test %d got error at %d:%d; want %d:%d", i,
+				loc.Lineno, loc.Offset, test.lineno, test.offset)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package indentfile
+
+import (
+	"io"
+)
+
+// TokenBlockHandler is implemented by directive contexts
+// that read their block straight from the token stream,
+// rather than as a sequence of directives.
+// This allows blocks with a custom grammar,
+// such as a table whose lines are rows rather than directives.
+//
+// When a directive returns a TokenBlockHandler
+// and is followed by an indented block,
+// ParseTokens calls ParseBlock just after the IndentToken.
+// ParseBlock must consume every token of the block,
+// up to and including the OutdentToken that ends it,
+// for example by calling SkipBlock.
+// End is not called on a TokenBlockHandler.
+//
+// ParseNodes and ParseDocument do not have a token stream,
+// so they treat a TokenBlockHandler like any other context.
+type TokenBlockHandler interface {
+	ParseBlock(tok *Tokenizer) error
+}
+
+type lookahead struct {
+	tok Token
+	err error
+}
+
+// Peek returns the next token in the stream without consuming it.
+func (t *Tokenizer) Peek() (Token, error) {
+	tokens, err := t.PeekN(1)
+	if len(tokens) == 0 {
+		return nil, err
+	}
+
+	return tokens[0], nil
+}
+
+// PeekN returns up to the next n tokens in the stream
+// without consuming them.
+// If fewer than n tokens are returned,
+// the error explains why, such as io.EOF at the end of the file.
+//
+// Peeked tokens have already been read by the tokenizer,
+// so any pragmas before them are available from TakePragmas.
+func (t *Tokenizer) PeekN(n int) ([]Token, error) {
+	for len(t.lookahead) < n {
+		last := len(t.lookahead) - 1
+		if last >= 0 && t.lookahead[last].err != nil {
+			break
+		}
+
+		tok, err := t.next()
+		t.lookahead = append(t.lookahead, lookahead{tok, err})
+	}
+
+	tokens := make([]Token, 0, n)
+	for _, next := range t.lookahead {
+		if len(tokens) == n {
+			break
+		} else if next.err != nil {
+			return tokens, next.err
+		}
+
+		tokens = append(tokens, next.tok)
+	}
+
+	return tokens, nil
+}
+
+// Unread pushes tok back onto the front of the stream,
+// so that it is returned by the next call to Next or Peek.
+// Tokens may be unread in any number,
+// and are returned in the reverse order to that they were unread.
+func (t *Tokenizer) Unread(tok Token) {
+	t.lookahead = append([]lookahead{{tok, nil}}, t.lookahead...)
+}
+
+// SkipBlock consumes every token up to and including
+// the OutdentToken that ends the current block,
+// including any blocks nested inside it.
+// Call it just after reading an IndentToken to skip that block.
+func (t *Tokenizer) SkipBlock() error {
+	depth := 0
+	for {
+		tok, err := t.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		switch tok.Type() {
+		case IndentToken:
+			depth++
+
+		case OutdentToken:
+			if depth == 0 {
+				return nil
+			}
+
+			depth--
+		}
+	}
+}
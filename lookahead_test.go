@@ -0,0 +1,125 @@
+package indentfile
+
+import (
+	"container/list"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPeekUnread(t *testing.T) {
+	tok := NewTokenizer(strings.NewReader("a b\n  c\nd\n"))
+
+	peeked, err := tok.PeekN(3)
+	if err != nil {
+		t.Fatalf("PeekN returned error: %v", err)
+	} else if len(peeked) != 3 || string(peeked[2].Text()) != "\n" {
+		t.Fatalf("Got %d peeked tokens", len(peeked))
+	}
+
+	first, _ := tok.Next()
+	if first != peeked[0] {
+		t.Errorf("Next returned %q; want the first peeked token", first.Text())
+	}
+
+	tok.Unread(first)
+	if next, _ := tok.Peek(); next != first {
+		t.Errorf("Peek returned %q; want the unread token", next.Text())
+	}
+
+	var words []string
+	for {
+		next, err := tok.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Next returned error: %v", err)
+		}
+
+		words = append(words, tokenTypeName(next.Type()))
+	}
+
+	expect := []string{
+		"WordToken", "WordToken", "TerminatorToken",
+		"IndentToken", "WordToken", "TerminatorToken",
+		"OutdentToken", "WordToken", "TerminatorToken",
+	}
+
+	if !reflect.DeepEqual(words, expect) {
+		t.Errorf("Got %v; want %v", words, expect)
+	}
+
+	if _, err := tok.PeekN(2); err != io.EOF {
+		t.Errorf("PeekN at end returned %v; want EOF", err)
+	}
+}
+
+type tableCtx struct {
+	msgCtx
+}
+
+func (c *tableCtx) Table() *tableBlock {
+	return &tableBlock{c}
+}
+
+type tableBlock struct {
+	ctx *tableCtx
+}
+
+func (b *tableBlock) ParseBlock(tok *Tokenizer) error {
+	var row []string
+	for {
+		next, err := tok.Next()
+		if err != nil {
+			return err
+		}
+
+		switch next.Type() {
+		case WordToken:
+			if text := string(next.Text()); text != "|" {
+				row = append(row, text)
+			}
+
+		case TerminatorToken:
+			b.ctx.Msg(row...)
+			row = nil
+
+		case IndentToken:
+			if err := tok.SkipBlock(); err != nil {
+				return err
+			}
+
+		case OutdentToken:
+			return nil
+		}
+	}
+}
+
+func TestTokenBlockHandler(t *testing.T) {
+	messages := list.New()
+	ctx := &tableCtx{msgCtx{messages, ""}}
+
+	err := ParseFile("test_files/parse/table.txt", ctx)
+	if err != nil {
+		t.Fatalf("ParseFile returned error: %v", err)
+	}
+
+	expect := []string{
+		"before",
+		"name port",
+		"api 80",
+		"admin 8080",
+		"after",
+		"<end>",
+	}
+
+	node := messages.Front()
+	for i, msg := range expect {
+		if node == nil || node.Value.(string) != msg {
+			t.Fatalf("Got %d = %v; want %q", i, node, msg)
+		}
+
+		node = node.Next()
+	}
+}
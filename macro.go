@@ -0,0 +1,233 @@
+package indentfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// MaxMacroDepth limits how deeply ExpandMacros will expand
+// macros used inside other macros.
+const MaxMacroDepth = 32
+
+// ExpandMacros returns a copy of doc with all macros expanded.
+//
+// A macro is defined with a "define" directive,
+// giving the macro's name and the names of its parameters,
+// with the directives it expands to in its block:
+//
+//	define backend name port
+//	    server $name
+//	        listen $port
+//	        proxy {"upstream": "${name}"}
+//
+//	use backend api 8080
+//	use backend web 8081
+//
+// Each "use" directive is replaced by the block of the named macro,
+// with every $param or ${param} in its words
+// replaced by the corresponding argument.
+// In JSON arguments, only the ${param} form is replaced,
+// and the value is escaped for use inside a JSON string.
+// Unrecognised variables are left alone.
+//
+// A macro can be used anywhere after its definition
+// in the same block or in a block nested inside it.
+// Macros may use other macros, but not recursively,
+// and no more than MaxMacroDepth deep.
+//
+// Every expanded directive keeps the location of its definition,
+// and has ExpandedFrom set to the "use" directive it came from.
+// Errors in expanded directives reported by ParseDocument
+// give both locations.
+func ExpandMacros(doc *Document) (*Document, error) {
+	children, err := expandNodes(doc.Children, &macroScope{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	pragmas := append([]Pragma(nil), doc.Pragmas...)
+	return &Document{File: doc.File, Pragmas: pragmas, Children: children}, nil
+}
+
+type macroScope struct {
+	parent *macroScope
+	macros map[string]*Node
+}
+
+func (scope *macroScope) lookup(name string) *Node {
+	for ; scope != nil; scope = scope.parent {
+		if macro, has := scope.macros[name]; has {
+			return macro
+		}
+	}
+
+	return nil
+}
+
+func expandNodes(nodes []*Node, scope *macroScope, stack []string) (result []*Node, err error) {
+	for _, node := range nodes {
+		switch node.Name {
+		case "define":
+			words := node.Words()
+			if len(words) == 0 || len(words) != len(node.Args) {
+				return nil, node.errorInFile(errorAtf(ErrMacro, node.Location,
+					"define takes a name and parameter names"))
+			}
+
+			if scope.macros == nil {
+				scope.macros = make(map[string]*Node)
+			}
+
+			scope.macros[words[0]] = node
+
+		case "use":
+			var expanded []*Node
+			expanded, err = expandUse(node, scope, stack)
+			if err != nil {
+				return
+			}
+
+			result = append(result, expanded...)
+
+		default:
+			expanded := node.copy()
+			expanded.Children, err = expandNodes(node.Children,
+				&macroScope{parent: scope}, stack)
+			if err != nil {
+				return
+			}
+
+			result = append(result, expanded)
+		}
+	}
+
+	return
+}
+
+func expandUse(use *Node, scope *macroScope, stack []string) ([]*Node, error) {
+	words := use.Words()
+	if len(words) == 0 || len(words) != len(use.Args) {
+		return nil, use.errorInFile(errorAtf(ErrMacro, use.Location,
+			"use takes a macro name and arguments"))
+	}
+
+	name := words[0]
+	macro := scope.lookup(name)
+	if macro == nil {
+		return nil, use.errorInFile(errorAtf(ErrMacro, use.Location,
+			"undefined macro %q", name))
+	}
+
+	for _, active := range stack {
+		if active == name {
+			return nil, use.errorInFile(errorAtf(ErrMacro, use.Location,
+				"macro %q used recursively", name))
+		}
+	}
+
+	if len(stack) >= MaxMacroDepth {
+		return nil, use.errorInFile(errorAtf(ErrMacro, use.Location,
+			"macros nested more than %d deep", MaxMacroDepth))
+	}
+
+	params := macro.Words()[1:]
+	if len(words)-1 != len(params) {
+		return nil, use.errorInFile(errorAtf(ErrMacro, use.Location,
+			"macro %q takes %d arguments, not %d",
+			name, len(params), len(words)-1))
+	}
+
+	values := make(map[string]string, len(params))
+	for i, param := range params {
+		values[param] = words[i+1]
+	}
+
+	body := make([]*Node, len(macro.Children))
+	for i, node := range macro.Children {
+		body[i] = substituteNode(node, values, use)
+	}
+
+	return expandNodes(body, &macroScope{parent: scope}, append(stack, name))
+}
+
+func substituteNode(node *Node, values map[string]string, use *Node) *Node {
+	result := node.copy()
+	result.Name = substitute(node.Name, values, false)
+	for i, arg := range result.Args {
+		result.Args[i].Text = substitute(arg.Text, values, arg.JSON)
+	}
+
+	result.ExpandedFrom = use
+	for i, child := range node.Children {
+		result.Children[i] = substituteNode(child, values, use)
+	}
+
+	return result
+}
+
+// substitute replaces the parameters in text with their values.
+// In JSON, only braced parameters are replaced,
+// and their values are escaped as JSON string contents.
+func substitute(text string, values map[string]string, inJSON bool) string {
+	var b strings.Builder
+	for {
+		dollar := strings.IndexByte(text, '$')
+		if dollar < 0 || dollar+1 == len(text) {
+			break
+		}
+
+		b.WriteString(text[:dollar])
+		text = text[dollar+1:]
+
+		var name, rest string
+		if text[0] == '{' {
+			end := strings.IndexByte(text, '}')
+			if end < 0 {
+				b.WriteByte('$')
+				continue
+			}
+
+			name, rest = text[1:end], text[end+1:]
+		} else if !inJSON {
+			end := 0
+			for end < len(text) && isParamChar(text[end]) {
+				end++
+			}
+
+			name, rest = text[:end], text[end:]
+		}
+
+		if value, has := values[name]; has && name != "" {
+			if inJSON {
+				value = jsonStringContents(value)
+			}
+
+			b.WriteString(value)
+			text = rest
+		} else {
+			b.WriteByte('$')
+		}
+	}
+
+	b.WriteString(text)
+	return b.String()
+}
+
+func isParamChar(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') ||
+		('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// jsonStringContents escapes value
+// to be written between the quotes of a JSON string.
+func jsonStringContents(value string) string {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+
+	// Drop the quotes and the newline Encode adds.
+	quoted := b.Bytes()
+	return string(quoted[1 : len(quoted)-2])
+}
@@ -0,0 +1,158 @@
+package indentfile
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExpandMacros(t *testing.T) {
+	doc, err := ReadDocumentFile("test_files/macro/blocks.txt")
+	if err != nil {
+		t.Fatalf("ReadDocumentFile returned error: %v", err)
+	}
+
+	doc, err = ExpandMacros(doc)
+	if err != nil {
+		t.Fatalf("ExpandMacros returned error: %v", err)
+	}
+
+	messages := list.New()
+	err = ParseDocument(doc, &msgCtx{messages, ""})
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %v", err)
+	}
+
+	expect := []string{
+		"hello world",
+		"$who" + "world!" + "$who",
+		"outer hi loudly",
+		"outer inner hello friends",
+		"outer inner $who" + "friends!" + "$who",
+		"<outer inner end>",
+		"<outer end>",
+		"<end>",
+	}
+
+	var actual []string
+	for node := messages.Front(); node != nil; node = node.Next() {
+		actual = append(actual, node.Value.(string))
+	}
+
+	if strings.Join(actual, "|") != strings.Join(expect, "|") {
+		t.Errorf("Got %q; want %q", actual, expect)
+	}
+}
+
+func TestMacroErrors(t *testing.T) {
+	doc, err := ReadDocumentFile("test_files/macro/errors.txt")
+	if err != nil {
+		t.Fatalf("ReadDocumentFile returned error: %v", err)
+	}
+
+	doc, err = ExpandMacros(doc)
+	if err != nil {
+		t.Fatalf("ExpandMacros returned error: %v", err)
+	}
+
+	err = ParseDocument(doc, &msgCtx{list.New(), ""})
+	if !errors.Is(err, ErrUnknown) {
+		t.Fatalf("ParseDocument error = %v; want ErrUnknown", err)
+	}
+
+	if loc := ErrorLocation(err); loc.Lineno != 3 || loc.Offset != 5 {
+		t.Errorf("Error location = %v; want line 3:5", loc)
+	}
+
+	const file = "test_files/macro/errors.txt"
+	expect := `unknown directive in file ` + file + ` (3:5): "bogus"` +
+		` (expanded from file ` + file + ` (6:5))` +
+		` (expanded from file ` + file + ` (8:1))`
+	if err.Error() != expect {
+		t.Errorf("Error = %q; want %q", err, expect)
+	}
+
+	cases := map[string]string{
+		"use missing\n":                                     "undefined macro",
+		"define a\n    use a\nuse a\n":                      "used recursively",
+		"define a x\n    msg $x\nuse a\n":                   "takes 1 arguments, not 0",
+		"prefix p\n    define a\nuse a\n":                   "undefined macro",
+		"define a {}\n":                                     "define takes a name",
+		"define a\n    msg\nuse a {}\n":                     "use takes a macro name",
+		"define a\n    use b\ndefine b\n    use a\nuse a\n": "used recursively",
+	}
+
+	for source, detail := range cases {
+		doc, err := ReadDocument(strings.NewReader(source))
+		if err != nil {
+			t.Fatalf("ReadDocument(%q) returned error: %v", source, err)
+		}
+
+		_, err = ExpandMacros(doc)
+		if !errors.Is(err, ErrMacro) || !strings.Contains(err.Error(), detail) {
+			t.Errorf("ExpandMacros(%q) error = %v; want %q", source, err, detail)
+		}
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	values := map[string]string{"a": "1", "long_name": "2"}
+	cases := []struct {
+		text, expect, expectBraced string
+	}{
+		{"$a", "1", "$a"},
+		{"${a}", "1", "1"},
+		{"x$a-y", "x1-y", "x$a-y"},
+		{"$ab", "$ab", "$ab"},
+		{"${a}b$long_name", "1b2", "1b$long_name"},
+		{"$", "$", "$"},
+		{"${a", "${a", "${a"},
+		{"$$a", "$1", "$$a"},
+	}
+
+	for _, c := range cases {
+		if actual := substitute(c.text, values, false); actual != c.expect {
+			t.Errorf("substitute(%q) = %q; want %q", c.text, actual, c.expect)
+		}
+
+		if actual := substitute(c.text, values, true); actual != c.expectBraced {
+			t.Errorf("substitute(%q, JSON) = %q; want %q",
+				c.text, actual, c.expectBraced)
+		}
+	}
+}
+
+func TestSubstituteJSON(t *testing.T) {
+	value := `say "hi" \ <bye>` + "\n"
+	text := `{"msg": "${v}"}`
+	actual := substitute(text, map[string]string{"v": value}, true)
+
+	var decoded struct{ Msg string }
+	if err := json.Unmarshal([]byte(actual), &decoded); err != nil {
+		t.Fatalf("substitute(%q) = %q, which is not valid JSON: %v",
+			text, actual, err)
+	}
+
+	if decoded.Msg != value {
+		t.Errorf("substitute(%q) decodes to %q; want %q", text, decoded.Msg, value)
+	}
+}
+
+func TestExpandMacrosPragmas(t *testing.T) {
+	doc, err := ReadDocumentFile("test_files/pragma/pragmas.txt")
+	if err != nil {
+		t.Fatalf("ReadDocumentFile returned error: %v", err)
+	}
+
+	expanded, err := ExpandMacros(doc)
+	if err != nil {
+		t.Fatalf("ExpandMacros returned error: %v", err)
+	}
+
+	if len(expanded.Pragmas) != len(doc.Pragmas) || len(expanded.Pragmas) == 0 {
+		t.Errorf("Expanded document has %d pragmas; want %d",
+			len(expanded.Pragmas), len(doc.Pragmas))
+	}
+}
@@ -0,0 +1,185 @@
+package indentfile
+
+// MatchKeys says how directives are matched between two documents.
+// Each entry gives the number of leading word arguments
+// that identify a directive of that name,
+// so that, for example, {"server": 1} matches "server api" with
+// "server api extra" but not with "server web".
+// Directives with no entry are identified by all of their word arguments.
+type MatchKeys map[string]int
+
+func (keys MatchKeys) match(a, b *Node) bool {
+	if a.Name != b.Name {
+		return false
+	}
+
+	n, has := keys[a.Name]
+	aWords := a.Words()
+	bWords := b.Words()
+	if has {
+		if len(aWords) > n {
+			aWords = aWords[:n]
+		}
+
+		if len(bWords) > n {
+			bWords = bWords[:n]
+		}
+	}
+
+	if len(aWords) != len(bWords) {
+		return false
+	}
+
+	for i := range aWords {
+		if aWords[i] != bWords[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Merge applies overlay on top of base, returning a new Document.
+// Neither base nor overlay is modified.
+//
+// Each directive in the overlay is matched with the directives
+// in the same block of base using keys.
+// The first character of an overlay directive's name
+// selects how it is applied:
+//
+//	name   merge: the first match takes the overlay's arguments,
+//	       and the overlay's block is merged into the match's block
+//	+name  append: the directive is added after the existing ones
+//	=name  replace: the first match is replaced entirely
+//	-name  delete: every match is removed
+//
+// If nothing matches, merged and replaced directives are appended,
+// and deletions do nothing.
+// A deletion may not have a block.
+//
+// Every directive in the result keeps the File and Location
+// it was read from, so errors from ParseDocument
+// and the results of a Query refer to the original source.
+// A merged directive whose arguments did not change
+// keeps the location from base.
+// The result has the file-level pragmas of both documents,
+// those of base first.
+func Merge(base, overlay *Document, keys MatchKeys) (*Document, error) {
+	children, err := mergeNodes(base.Children, overlay.Children, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var pragmas []Pragma
+	pragmas = append(pragmas, base.Pragmas...)
+	pragmas = append(pragmas, overlay.Pragmas...)
+
+	return &Document{File: base.File, Pragmas: pragmas, Children: children}, nil
+}
+
+func mergeNodes(base, overlay []*Node, keys MatchKeys) ([]*Node, error) {
+	result := make([]*Node, len(base))
+	for i, node := range base {
+		result[i] = node.copy()
+	}
+
+	for _, over := range overlay {
+		op := byte(0)
+		if len(over.Name) > 1 {
+			switch over.Name[0] {
+			case '+', '=', '-':
+				op = over.Name[0]
+			}
+		}
+
+		node := over.copy()
+		if op != 0 {
+			node.Name = node.Name[1:]
+		}
+
+		if op == '-' {
+			if len(over.Children) > 0 {
+				return nil, over.errorInFile(errorAtf(ErrOverlay,
+					over.Children[0].Location,
+					"deleted directive %q cannot have a block", node.Name))
+			}
+
+			kept := result[:0]
+			for _, existing := range result {
+				if !keys.match(existing, node) {
+					kept = append(kept, existing)
+				}
+			}
+
+			result = kept
+			continue
+		}
+
+		index := -1
+		if op != '+' {
+			for i, existing := range result {
+				if keys.match(existing, node) {
+					index = i
+					break
+				}
+			}
+		}
+
+		var err error
+		if index >= 0 && op == 0 {
+			existing := result[index]
+			if sameArgs(existing, node) {
+				// Nothing changed on this line,
+				// so keep pointing at where it was first written.
+				node.Args = existing.Args
+				node.File = existing.File
+				node.Location = existing.Location
+				node.end = existing.end
+			}
+
+			node.Children, err = mergeNodes(
+				existing.Children, over.Children, keys)
+		} else {
+			node.Children, err = mergeNodes(nil, over.Children, keys)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if index >= 0 {
+			result[index] = node
+		} else {
+			result = append(result, node)
+		}
+	}
+
+	return result, nil
+}
+
+func sameArgs(a, b *Node) bool {
+	if len(a.Args) != len(b.Args) {
+		return false
+	}
+
+	for i := range a.Args {
+		if a.Args[i].Text != b.Args[i].Text || a.Args[i].JSON != b.Args[i].JSON {
+			return false
+		}
+	}
+
+	return true
+}
+
+// copy returns a copy of the tree under n
+// that shares no slices with the original.
+func (n *Node) copy() *Node {
+	c := *n
+	c.Args = append([]Arg(nil), n.Args...)
+	c.Children = make([]*Node, len(n.Children))
+	for i, child := range n.Children {
+		c.Children[i] = child.copy()
+	}
+
+	return &c
+}
@@ -0,0 +1,124 @@
+package indentfile
+
+import (
+	"container/list"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	base, err := ReadDocumentFile("test_files/merge/base.txt")
+	if err != nil {
+		t.Fatalf("ReadDocumentFile returned error: %v", err)
+	}
+
+	overlay, err := ReadDocumentFile("test_files/merge/overlay.txt")
+	if err != nil {
+		t.Fatalf("ReadDocumentFile returned error: %v", err)
+	}
+
+	merged, err := Merge(base, overlay, MatchKeys{"server": 1, "log": 0})
+	if err != nil {
+		t.Fatalf("Merge returned error: %v", err)
+	}
+
+	expect := []struct {
+		query string
+		lines []string
+	}{
+		{"server", []string{
+			"base.txt:1 server api",
+			"overlay.txt:6 server web",
+			"overlay.txt:9 server admin",
+		}},
+		{"server[api]/*", []string{
+			"base.txt:3 listen 443",
+			"overlay.txt:3 log debug",
+			"overlay.txt:4 listen 8443",
+		}},
+		{"server[web]/*", []string{"overlay.txt:7 listen 9090"}},
+		{"msg", []string{
+			"base.txt:9 msg hello",
+			"overlay.txt:12 msg unknown-directive-below",
+		}},
+	}
+
+	for _, e := range expect {
+		nodes, _ := merged.Query(e.query)
+		if len(nodes) != len(e.lines) {
+			t.Errorf("Query(%q) got %d nodes; want %d",
+				e.query, len(nodes), len(e.lines))
+			continue
+		}
+
+		for i, node := range nodes {
+			actual := node.File[len("test_files/merge/"):] + ":" +
+				strconv.Itoa(node.Location.Lineno) + " " + node.String()
+			if actual != e.lines[i] {
+				t.Errorf("Query(%q) result %d = %q; want %q",
+					e.query, i, actual, e.lines[i])
+			}
+		}
+	}
+
+	if len(base.Children[0].Children) != 3 {
+		t.Errorf("Merge modified base document")
+	}
+
+	err = ParseDocument(merged, &msgCtx{})
+	if !errors.Is(err, ErrUnknown) {
+		t.Fatalf("ParseDocument error = %v; want ErrUnknown", err)
+	}
+
+	expectErr := `unknown directive in file test_files/merge/base.txt (1:1): "server"`
+	if err.Error() != expectErr {
+		t.Errorf("ParseDocument error = %q; want %q", err, expectErr)
+	}
+	// Directives from the overlay report the overlay's file.
+	const overlayPath = "test_files/merge/overlay.txt"
+	admin, _ := merged.Query("server[admin]//*")
+	if len(admin) != 1 || admin[0].File != overlayPath {
+		t.Errorf("Query(server[admin]//*) got %v; want a node from %s", admin, overlayPath)
+	}
+
+	ctx := &collectCtx{msgCtx: msgCtx{list.New(), ""}}
+	err = (&Parser{Unknown: UnknownCollect}).ParseDocument(merged, ctx)
+	if err != nil {
+		t.Fatalf("ParseDocument with UnknownCollect returned error: %v", err)
+	}
+
+	last := ctx.Unknown[len(ctx.Unknown)-1]
+	if last.Name != "bogus" || last.File != overlayPath || last.Location.Lineno != 13 {
+		t.Errorf("Last unknown directive is %q from %s:%d; want bogus from %s:13",
+			last, last.File, last.Location.Lineno, overlayPath)
+	}
+}
+
+func TestMergeDeleteBlock(t *testing.T) {
+	base := &Document{}
+	overlay := &Document{Children: []*Node{{
+		Name:     "-server",
+		Children: []*Node{{Name: "listen"}},
+	}}}
+
+	_, err := Merge(base, overlay, nil)
+	if !errors.Is(err, ErrOverlay) {
+		t.Errorf("Merge error = %v; want ErrOverlay", err)
+	}
+}
+
+func TestMergePragmas(t *testing.T) {
+	base := &Document{Pragmas: []Pragma{{Name: "indentfile"}}}
+	overlay := &Document{Pragmas: []Pragma{{Name: "generated"}}}
+
+	merged, err := Merge(base, overlay, nil)
+	if err != nil {
+		t.Fatalf("Merge returned error: %v", err)
+	}
+
+	if len(merged.Pragmas) != 2 || merged.Pragmas[0].Name != "indentfile" ||
+		merged.Pragmas[1].Name != "generated" {
+		t.Errorf("Merged document has pragmas %v", merged.Pragmas)
+	}
+}
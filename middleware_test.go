@@ -0,0 +1,122 @@
+package indentfile
+
+import (
+	"container/list"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	var names []string
+	logNames := func(next ArgsDirectiveHandler) ArgsDirectiveHandler {
+		return ArgsHandlerFunc(func(name string, args []Arg) (interface{}, error) {
+			names = append(names, name)
+			return next.ArgsDirective(name, args)
+		})
+	}
+
+	shout := func(next ArgsDirectiveHandler) ArgsDirectiveHandler {
+		return ArgsHandlerFunc(func(name string, args []Arg) (interface{}, error) {
+			if name == "msg" {
+				for i := range args {
+					args[i].Text = strings.ToUpper(args[i].Text)
+				}
+			}
+
+			return next.ArgsDirective(name, args)
+		})
+	}
+
+	messages := list.New()
+	parser := &Parser{Middleware: []Middleware{logNames, shout}}
+	err := parser.ParseFile("test_files/parse/simple.txt", &msgCtx{messages, ""})
+	if err != nil {
+		t.Fatalf("ParseFile returned error: %v", err)
+	}
+
+	expectNames := []string{"msg", "prefix", "msg", "msg", "prefix", "msg", "msg", "msg"}
+	if !reflect.DeepEqual(names, expectNames) {
+		t.Errorf("Middleware saw %q; want %q", names, expectNames)
+	}
+
+	if msg := messages.Front().Value.(string); msg != "HELLO WORLD" {
+		t.Errorf("Got first message %q; want %q", msg, "HELLO WORLD")
+	}
+
+	if msg := messages.Front().Next().Value.(string); msg != "* SAYS HELLO" {
+		t.Errorf("Got nested message %q; want %q", msg, "* SAYS HELLO")
+	}
+
+	errDenied := errors.New("not allowed here")
+	deny := func(next ArgsDirectiveHandler) ArgsDirectiveHandler {
+		return ArgsHandlerFunc(func(name string, args []Arg) (interface{}, error) {
+			if name == "prefix" {
+				return nil, DirectiveErrorf("%w %q", errDenied, name)
+			}
+
+			return next.ArgsDirective(name, args)
+		})
+	}
+
+	parser = &Parser{Middleware: []Middleware{deny}}
+	err = parser.ParseFile("test_files/parse/simple.txt", &msgCtx{list.New(), ""})
+	if !errors.Is(err, errDenied) {
+		t.Errorf("Got error %v; want %v", err, errDenied)
+	} else if loc := ErrorLocation(err); loc.Lineno != 4 || loc.Offset != 1 {
+		t.Errorf("Got error at %d:%d; want 4:1", loc.Lineno, loc.Offset)
+	}
+
+	doc, err := ReadDocumentFile("test_files/parse/simple.txt")
+	if err != nil {
+		t.Fatalf("ReadDocumentFile returned error: %v", err)
+	}
+
+	names = nil
+	parser = &Parser{Middleware: []Middleware{logNames}}
+	err = parser.ParseDocument(doc, &msgCtx{list.New(), ""})
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %v", err)
+	} else if !reflect.DeepEqual(names, expectNames) {
+		t.Errorf("Middleware saw %q in document; want %q", names, expectNames)
+	}
+}
+
+func TestMiddlewareFallback(t *testing.T) {
+	var names []string
+	logNames := func(next ArgsDirectiveHandler) ArgsDirectiveHandler {
+		return ArgsHandlerFunc(func(name string, args []Arg) (interface{}, error) {
+			names = append(names, name)
+			return next.ArgsDirective(name, args)
+		})
+	}
+
+	var fallback []string
+	record := HandlerFunc(func(name string, argv []string) (interface{}, error) {
+		fallback = append(fallback, name)
+		return nil, nil
+	})
+
+	mux := NewMux()
+	mux.HandleFunc("known", record)
+	mux.Fallback = record
+
+	for name, context := range map[string]interface{}{
+		"Parser": &msgCtx{list.New(), ""},
+		"Mux":    mux,
+	} {
+		names, fallback = nil, nil
+		parser := &Parser{Middleware: []Middleware{logNames}, Fallback: record}
+		err := parser.Parse(strings.NewReader("msg hi\nother\n"), context)
+		if err != nil {
+			t.Errorf("%s fallback returned error: %v", name, err)
+		} else if expect := []string{"msg", "other"}; !reflect.DeepEqual(names, expect) {
+			t.Errorf("%s fallback: middleware saw %q; want %q", name, names, expect)
+		}
+
+		if len(fallback) == 0 || fallback[len(fallback)-1] != "other" {
+			t.Errorf("%s fallback got %q", name, fallback)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package indentfile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// JSONUse selects whether a directive takes a JSON argument.
+type JSONUse int
+
+// The valid JSONUse values.
+const (
+	// The directive may not have a JSON argument.
+	JSONForbidden = JSONUse(iota)
+	// The directive may have a JSON argument.
+	JSONOptional
+	// The directive must have a JSON argument.
+	JSONRequired
+)
+
+// Arity describes the arguments a Mux accepts for a directive.
+type Arity struct {
+	// The fewest words the directive takes.
+	Min int
+	// The most words the directive takes,
+	// or -1 for any number.
+	// Zero means the same as Min,
+	// so Arity{Min: 1} takes exactly one word.
+	Max int
+	// Whether the directive takes a JSON argument.
+	JSON JSONUse
+}
+
+func (a Arity) signature(name string) string {
+	parts := []string{name}
+	for i := 0; i < a.Min; i++ {
+		parts = append(parts, "<word>")
+	}
+
+	if a.Max < 0 {
+		parts = append(parts, "[<word>...]")
+	} else {
+		for i := a.Min; i < a.Max; i++ {
+			parts = append(parts, "[<word>]")
+		}
+	}
+
+	switch a.JSON {
+	case JSONOptional:
+		parts = append(parts, "[<json>]")
+	case JSONRequired:
+		parts = append(parts, "<json>")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Mux is a context that passes each directive
+// to the handler registered for its name,
+// much like http.ServeMux does for requests.
+// Handlers should be registered before the Mux is used.
+//
+// A Mux can be the context for the block of a directive,
+// using HandleMux,
+// so that a tree of Muxes describes a whole file.
+type Mux struct {
+	// If not nil, the context for directives without a handler.
+	// Otherwise they are an error wrapping ErrUnknown.
+	// A Parser maps directive names to the methods of Fallback
+	// with its Names.
+	Fallback interface{}
+
+	entries map[string]*muxEntry
+}
+
+type muxEntry struct {
+	handler DirectiveHandler
+	arity   *Arity
+}
+
+// NewMux returns an empty Mux.
+func NewMux() *Mux {
+	return &Mux{}
+}
+
+// Handle registers the handler for the directive called name.
+// If handler implements ObjectDirectiveHandler,
+// the directive may have a JSON argument.
+// It is an error to register the same name twice,
+// or to register a nil handler.
+func (m *Mux) Handle(name string, handler DirectiveHandler) error {
+	if handler == nil {
+		return fmt.Errorf("directive %q registered with nil handler", name)
+	} else if _, exists := m.entries[name]; exists {
+		return fmt.Errorf("directive %q registered twice", name)
+	}
+
+	if m.entries == nil {
+		m.entries = make(map[string]*muxEntry)
+	}
+
+	m.entries[name] = &muxEntry{handler: handler}
+	return nil
+}
+
+// HandleFunc registers the handler function for the directive called name.
+func (m *Mux) HandleFunc(name string, fn HandlerFunc) error {
+	if fn == nil {
+		return m.Handle(name, nil)
+	}
+
+	return m.Handle(name, fn)
+}
+
+// HandleObjectFunc registers the handler function
+// for the directive called name,
+// which may have a JSON argument.
+func (m *Mux) HandleObjectFunc(name string, fn ObjectHandlerFunc) error {
+	if fn == nil {
+		return m.Handle(name, nil)
+	}
+
+	return m.Handle(name, fn)
+}
+
+// HandleMux registers the directive called name
+// to use child as the context for its block.
+// The directive takes no arguments unless Require allows them.
+func (m *Mux) HandleMux(name string, child *Mux) error {
+	err := m.Handle(name, HandlerFunc(func(name string, argv []string) (interface{}, error) {
+		return child, nil
+	}))
+	if err != nil {
+		return err
+	}
+
+	return m.Require(name, Arity{})
+}
+
+// Require restricts the arguments accepted by the directive called name,
+// which must already be registered.
+// Without it, the handler receives any arguments.
+// It is an error for arity to allow fewer than Min words.
+func (m *Mux) Require(name string, arity Arity) error {
+	entry, exists := m.entries[name]
+	if !exists {
+		return fmt.Errorf("directive %q not registered", name)
+	}
+
+	if arity.Max == 0 {
+		arity.Max = arity.Min
+	}
+
+	if arity.Min < 0 || (arity.Max < arity.Min && arity.Max != -1) {
+		return fmt.Errorf("directive %q has bad arity %d to %d",
+			name, arity.Min, arity.Max)
+	}
+
+	entry.arity = &arity
+	return nil
+}
+
+// Directives returns the names of the registered directives,
+// in sorted order.
+func (m *Mux) Directives() []string {
+	names := make([]string, 0, len(m.entries))
+	for name := range m.entries {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Signature describes the arguments of the directive called name,
+// such as "listen <word> [<word>...]",
+// or returns "" if it has no requirements.
+func (m *Mux) Signature(name string) string {
+	entry, exists := m.entries[name]
+	if !exists || entry.arity == nil {
+		return ""
+	}
+
+	return entry.arity.signature(name)
+}
+
+// Directive passes a directive without a JSON argument to its handler.
+func (m *Mux) Directive(name string, argv []string) (interface{}, error) {
+	return m.ObjectDirective(name, argv, nil)
+}
+
+// ObjectDirective checks the arguments of a directive
+// against any requirements for it,
+// and passes it to its handler,
+// or to Fallback if it has none.
+func (m *Mux) ObjectDirective(name string, argv []string, json []byte) (interface{}, error) {
+	return m.handle(name, argv, json, nil)
+}
+
+func (m *Mux) withNames(names *NameMapping) ArgsDirectiveHandler {
+	return objectHandler{namedMux{m, names}}
+}
+
+// handle is ObjectDirective,
+// mapping directive names to the methods of Fallback with names.
+func (m *Mux) handle(name string, argv []string, json []byte, names *NameMapping) (interface{}, error) {
+	entry, exists := m.entries[name]
+	if !exists {
+		if m.Fallback != nil {
+			return getDirectiveHandlerFor(m.Fallback, names).ArgsDirective(name, joinArgs(argv, json))
+		}
+
+		return nil, unknownError(name, m.Directives())
+	}
+
+	if arity := entry.arity; arity != nil {
+		if len(argv) < arity.Min {
+			// Report the last word rather than any JSON argument after it.
+			if len(argv) == 0 {
+				return nil, DirectiveErrorf("not enough arguments (want %s)",
+					arity.signature(name))
+			}
+
+			return nil, ArgumentErrorf(len(argv)-1, "not enough arguments (want %s)",
+				arity.signature(name))
+		} else if arity.Max >= 0 && len(argv) > arity.Max {
+			return nil, ArgumentErrorf(arity.Max, "too many arguments (want %s)",
+				arity.signature(name))
+		} else if json == nil && arity.JSON == JSONRequired {
+			return nil, ArgumentErrorf(-1, "expected JSON argument (want %s)",
+				arity.signature(name))
+		} else if json != nil && arity.JSON == JSONForbidden {
+			return nil, ErrArgumentJSON
+		}
+	}
+
+	if json == nil {
+		return entry.handler.Directive(name, argv)
+	}
+
+	handler, is := entry.handler.(ObjectDirectiveHandler)
+	if !is {
+		return nil, ErrArgumentJSON
+	}
+
+	return handler.ObjectDirective(name, argv, json)
+}
+
+// namedMux is a Mux used by a Parser with a NameMapping.
+type namedMux struct {
+	mux   *Mux
+	names *NameMapping
+}
+
+func (h namedMux) Directive(name string, argv []string) (interface{}, error) {
+	return h.mux.handle(name, argv, nil, h.names)
+}
+
+func (h namedMux) ObjectDirective(name string, argv []string, json []byte) (interface{}, error) {
+	return h.mux.handle(name, argv, json, h.names)
+}
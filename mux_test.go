@@ -0,0 +1,128 @@
+package indentfile
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMux(t *testing.T) {
+	var called []string
+	record := func(name string, argv []string) (interface{}, error) {
+		called = append(called, name+" "+strings.Join(argv, " "))
+		return nil, nil
+	}
+
+	server := NewMux()
+	server.HandleFunc("listen", record)
+	server.Require("listen", Arity{Min: 1, Max: -1})
+	server.HandleObjectFunc("tls", func(name string, argv []string, json []byte) (interface{}, error) {
+		called = append(called, name+" "+string(json))
+		return nil, nil
+	})
+	server.Require("tls", Arity{JSON: JSONRequired})
+	server.HandleObjectFunc("cert", func(name string, argv []string, json []byte) (interface{}, error) {
+		return nil, nil
+	})
+	server.Require("cert", Arity{Min: 2, JSON: JSONOptional})
+
+	root := NewMux()
+	root.HandleMux("server", server)
+	root.HandleFunc("log", record)
+	root.Require("log", Arity{Min: 1})
+
+	if err := root.HandleFunc("log", record); err == nil {
+		t.Errorf("Registering log twice returned no error")
+	}
+
+	if err := root.Handle("nil", nil); err == nil {
+		t.Errorf("Registering a nil handler returned no error")
+	}
+
+	if err := root.HandleFunc("nil", nil); err == nil {
+		t.Errorf("Registering a nil HandlerFunc returned no error")
+	}
+
+	if err := root.HandleObjectFunc("nil", nil); err == nil {
+		t.Errorf("Registering a nil ObjectHandlerFunc returned no error")
+	}
+
+	if err := root.Require("missing", Arity{}); err == nil {
+		t.Errorf("Require for unregistered directive returned no error")
+	}
+
+	for _, arity := range []Arity{{Min: 2, Max: 1}, {Min: -1}, {Max: -2}} {
+		if err := root.Require("log", arity); err == nil {
+			t.Errorf("Require with arity %+v returned no error", arity)
+		}
+	}
+
+	if names := root.Directives(); !reflect.DeepEqual(names, []string{"log", "server"}) {
+		t.Errorf("Directives = %q", names)
+	}
+
+	if sig := server.Signature("listen"); sig != "listen <word> [<word>...]" {
+		t.Errorf("Signature = %q", sig)
+	}
+
+	source := "log debug\nserver\n    listen :80 :443\n    tls {\"cert\": \"a.pem\"}\n"
+	err := Parse(strings.NewReader(source), root)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	expect := []string{"log debug", "listen :80 :443", `tls {"cert": "a.pem"}`}
+	if !reflect.DeepEqual(called, expect) {
+		t.Errorf("Called %q; want %q", called, expect)
+	}
+
+	tests := []struct {
+		source  string
+		err     error
+		message string
+	}{
+		{"log", ErrDirective, "directive error at line 1:1: not enough arguments (want log <word>)"},
+		{"server\n    cert a {}", ErrArguments, "bad argument at line 2:10: not enough arguments (want cert <word> <word> [<json>])"},
+		{"log a b", ErrArguments, "bad argument at line 1:7: too many arguments (want log <word>)"},
+		{"log a {}", ErrArgumentJSON, "unexpected JSON at line 1:7"},
+		{"server\n    tls", ErrArguments, "bad argument at line 2:8: expected JSON argument (want tls <json>)"},
+		{"server a", ErrArguments, "bad argument at line 1:8: too many arguments (want server)"},
+		{"lgo a", ErrUnknown, `unknown directive at line 1:1: "lgo" (did you mean "log"?)`},
+	}
+
+	for _, test := range tests {
+		err := Parse(strings.NewReader(test.source), root)
+		if !errors.Is(err, test.err) {
+			t.Errorf("Parse(%q) error = %v; want %v", test.source, err, test.err)
+		} else if err.Error() != test.message {
+			t.Errorf("Parse(%q) error = %q; want %q", test.source, err, test.message)
+		}
+	}
+
+	named := NewMux()
+	named.HandleMux("named", NewMux())
+	named.Require("named", Arity{Min: 1})
+	if err := Parse(strings.NewReader("named a\n"), named); err != nil {
+		t.Errorf("Parse with required argument returned error: %v", err)
+	}
+
+	called = nil
+	root.Fallback = HandlerFunc(record)
+	err = Parse(strings.NewReader("other thing\n"), root)
+	if err != nil {
+		t.Fatalf("Parse with fallback returned error: %v", err)
+	} else if !reflect.DeepEqual(called, []string{"other thing"}) {
+		t.Errorf("Fallback called %q", called)
+	}
+
+	called = nil
+	root.Fallback = namesCtx{&called}
+	p := &Parser{Names: &NameMapping{Separators: "_"}}
+	err = p.Parse(strings.NewReader("listen_addr :80\n"), root)
+	if err != nil {
+		t.Fatalf("Parse with named fallback returned error: %v", err)
+	} else if !reflect.DeepEqual(called, []string{"ListenAddr :80"}) {
+		t.Errorf("Fallback called %q", called)
+	}
+}
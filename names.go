@@ -0,0 +1,282 @@
+package indentfile
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// NameMapping controls how the reflection API
+// maps directive names to the names of methods.
+//
+// The zero NameMapping splits directive names into words at each "-",
+// and joins the words with the first letter of each in upper case,
+// so that "listen-addr" calls the ListenAddr method.
+// Directive names containing upper case letters are rejected,
+// and names that do not start with a letter are prefixed with "X",
+// so that "2fa" calls the X2fa method.
+type NameMapping struct {
+	// The characters that separate the words of a directive name,
+	// such as "-_." to also accept snake_case and dotted names.
+	// The default is "-".
+	// The first separator is used when suggesting directive names.
+	Separators string
+	// Whether directive names are matched to methods ignoring case,
+	// so that "listen-tls" calls a ListenTLS method
+	// and "Listen" calls a Listen method.
+	CaseInsensitive bool
+	// Words written entirely in upper case in method names,
+	// such as "http" and "url" so that "http-url" calls HTTPURL.
+	Acronyms []string
+	// The methods for directives whose names can't be mapped
+	// to the right method by the rules above.
+	// These take precedence over the rules,
+	// and their keys are also matched ignoring case
+	// if CaseInsensitive is set.
+	Methods map[string]string
+}
+
+var defaultNames = &NameMapping{}
+
+func (m *NameMapping) separators() string {
+	if m.Separators == "" {
+		return "-"
+	}
+
+	return m.Separators
+}
+
+func (m *NameMapping) isAcronym(word string) bool {
+	for _, acronym := range m.Acronyms {
+		if strings.EqualFold(word, acronym) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// methodName returns the name of the method for a directive,
+// or false if the directive can't have a method.
+// The name may differ in case from that of the method
+// if the mapping is case insensitive.
+func (m *NameMapping) methodName(name string) (string, bool) {
+	if name == "" {
+		return "", false
+	} else if method, is := m.explicitMethod(name); is {
+		return method, true
+	}
+
+	if m.CaseInsensitive {
+		name = strings.ToLower(name)
+	} else if strings.ToLower(name) != name {
+		return "", false
+	}
+
+	words := strings.FieldsFunc(name, func(c rune) bool {
+		return strings.ContainsRune(m.separators(), c)
+	})
+
+	var b strings.Builder
+	for _, word := range words {
+		if m.isAcronym(word) {
+			b.WriteString(strings.ToUpper(word))
+			continue
+		}
+
+		first, size := utf8.DecodeRuneInString(word)
+		b.WriteRune(unicode.ToUpper(first))
+		b.WriteString(word[size:])
+	}
+
+	method := b.String()
+	if method == "" {
+		// The name is only separators.
+		return "", false
+	}
+
+	first, _ := utf8.DecodeRuneInString(method)
+	if unicode.ToUpper(first) == first && !unicode.IsUpper(first) {
+		method = "X" + method
+	}
+
+	return method, true
+}
+
+// explicitMethod looks up the method for a directive in m.Methods.
+func (m *NameMapping) explicitMethod(name string) (string, bool) {
+	if method, is := m.Methods[name]; is || !m.CaseInsensitive {
+		return method, is
+	}
+
+	// Use the first matching key,
+	// in case several differ only in case.
+	var match string
+	for key := range m.Methods {
+		if strings.EqualFold(key, name) && (match == "" || key < match) {
+			match = key
+		}
+	}
+
+	if match == "" {
+		return "", false
+	}
+
+	return m.Methods[match], true
+}
+
+// directiveName returns the name of the directive
+// that calls the method, reversing methodName,
+// or false if no directive calls it.
+func (m *NameMapping) directiveName(method string) (string, bool) {
+	var names []string
+	for name, target := range m.Methods {
+		if target == method {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) > 0 {
+		sort.Strings(names)
+		return names[0], true
+	}
+
+	pascal := method
+	if len(pascal) > 1 && pascal[0] == 'X' && !unicode.IsLetter(rune(pascal[1])) {
+		pascal = pascal[1:]
+	}
+
+	// The words of a method name are ambiguous,
+	// so try splitting at acronyms and runs of upper case letters first,
+	// then only at runs,
+	// and then at every upper case letter.
+	for _, split := range []splitMode{splitAcronyms, splitRuns, splitLetters} {
+		words := m.splitWords(pascal, split)
+		name := strings.ToLower(strings.Join(words, m.separators()[:1]))
+		if mapped, ok := m.methodName(name); ok && m.matches(mapped, method) {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+type splitMode int
+
+const (
+	splitAcronyms = splitMode(iota)
+	splitRuns
+	splitLetters
+)
+
+// splitWords splits a method name into words,
+// each starting with an upper case letter.
+func (m *NameMapping) splitWords(method string, split splitMode) []string {
+	var words []string
+	for len(method) > 0 {
+		length := 0
+		if split == splitAcronyms {
+			for _, acronym := range m.Acronyms {
+				upper := strings.ToUpper(acronym)
+				if len(upper) > length && strings.HasPrefix(method, upper) {
+					length = len(upper)
+				}
+			}
+		}
+
+		if length == 0 && split != splitLetters {
+			// A run of upper case letters is one word,
+			// except for a last letter that starts a lower case word.
+			for length < len(method) {
+				c, size := utf8.DecodeRuneInString(method[length:])
+				if !unicode.IsUpper(c) {
+					break
+				}
+
+				length += size
+			}
+
+			if length < len(method) && length > 1 {
+				_, size := utf8.DecodeLastRuneInString(method[:length])
+				length -= size
+			} else if length == 1 {
+				length = 0
+			}
+		}
+
+		if length == 0 {
+			_, length = utf8.DecodeRuneInString(method)
+			for length < len(method) {
+				c, size := utf8.DecodeRuneInString(method[length:])
+				if unicode.IsUpper(c) {
+					break
+				}
+
+				length += size
+			}
+		}
+
+		words = append(words, method[:length])
+		method = method[length:]
+	}
+
+	return words
+}
+
+// matches reports whether a method name from methodName
+// refers to the method called method.
+func (m *NameMapping) matches(mapped, method string) bool {
+	if m.CaseInsensitive {
+		return strings.EqualFold(mapped, method)
+	}
+
+	return mapped == method
+}
+
+var (
+	foldCacheLock sync.RWMutex
+	foldCache     = map[reflect.Type]map[string]string{}
+)
+
+// method returns the method of value for a directive,
+// which is not valid if there is none.
+func (m *NameMapping) method(value reflect.Value, name string) (string, reflect.Value) {
+	methodName, ok := m.methodName(name)
+	if !ok {
+		return "", reflect.Value{}
+	}
+
+	if m.CaseInsensitive {
+		if folded, is := foldedMethods(value.Type())[strings.ToLower(methodName)]; is {
+			methodName = folded
+		}
+	}
+
+	return methodName, value.MethodByName(methodName)
+}
+
+// foldedMethods maps the lower case names of the methods of t
+// to their actual names.
+func foldedMethods(t reflect.Type) map[string]string {
+	foldCacheLock.RLock()
+	methods, cached := foldCache[t]
+	foldCacheLock.RUnlock()
+	if cached {
+		return methods
+	}
+
+	methods = make(map[string]string, t.NumMethod())
+	for i := 0; i < t.NumMethod(); i++ {
+		name := t.Method(i).Name
+		methods[strings.ToLower(name)] = name
+	}
+
+	foldCacheLock.Lock()
+	foldCache[t] = methods
+	foldCacheLock.Unlock()
+
+	return methods
+}
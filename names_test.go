@@ -0,0 +1,95 @@
+package indentfile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type namesCtx struct {
+	called *[]string
+}
+
+func (c namesCtx) ListenAddr(addr string) { *c.called = append(*c.called, "ListenAddr "+addr) }
+func (c namesCtx) HTTPURL(url string)     { *c.called = append(*c.called, "HTTPURL "+url) }
+func (c namesCtx) ListenTLS()             { *c.called = append(*c.called, "ListenTLS") }
+func (c namesCtx) TwoFactor()             { *c.called = append(*c.called, "TwoFactor") }
+
+func TestNameMapping(t *testing.T) {
+	tests := []struct {
+		names  *NameMapping
+		name   string
+		method string
+	}{
+		{defaultNames, "listen-addr", "ListenAddr"},
+		{defaultNames, "2fa", "X2fa"},
+		{defaultNames, "listen_addr", "Listen_addr"},
+		{defaultNames, "Listen", ""},
+		{&NameMapping{Separators: "-_."}, "listen_addr", "ListenAddr"},
+		{&NameMapping{Separators: "-_."}, "listen.addr", "ListenAddr"},
+		{&NameMapping{Acronyms: []string{"http", "url"}}, "http-url", "HTTPURL"},
+		{&NameMapping{CaseInsensitive: true}, "Listen-Addr", "ListenAddr"},
+		{&NameMapping{Methods: map[string]string{"2fa": "TwoFactor"}}, "2fa", "TwoFactor"},
+		{&NameMapping{Methods: map[string]string{"2fa": "TwoFactor"}}, "2FA", ""},
+		{&NameMapping{CaseInsensitive: true, Methods: map[string]string{"2fa": "TwoFactor"}}, "2FA", "TwoFactor"},
+		{defaultNames, "", ""},
+		{&NameMapping{Separators: "-_"}, "-_", ""},
+		{&NameMapping{Methods: map[string]string{"": "TwoFactor"}}, "", ""},
+	}
+
+	for _, test := range tests {
+		method, ok := test.names.methodName(test.name)
+		if !ok {
+			method = ""
+		}
+
+		if method != test.method {
+			t.Errorf("%+v maps %q to %q; want %q", *test.names, test.name, method, test.method)
+		}
+	}
+
+	var called []string
+	ctx := namesCtx{&called}
+	parser := &Parser{Names: &NameMapping{
+		Separators:      "-_",
+		CaseInsensitive: true,
+		Acronyms:        []string{"http", "url"},
+		Methods:         map[string]string{"2fa": "TwoFactor"},
+	}}
+
+	source := "listen_addr :80\nhttp-url example.com\nlisten-tls\nLISTEN-ADDR :81\n2fa\n"
+	err := parser.Parse(strings.NewReader(source), ctx)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	expect := []string{"ListenAddr :80", "HTTPURL example.com", "ListenTLS", "ListenAddr :81", "TwoFactor"}
+	if !reflect.DeepEqual(called, expect) {
+		t.Errorf("Called %q; want %q", called, expect)
+	}
+
+	names := directiveNames(reflect.TypeOf(ctx), parser.Names)
+	expectNames := []string{"2fa", "http-url", "listen-addr", "listen-tls"}
+	if !reflect.DeepEqual(names, expectNames) {
+		t.Errorf("directiveNames = %q; want %q", names, expectNames)
+	}
+
+	err = parser.Parse(strings.NewReader("listen-adr :80\n"), ctx)
+	if suggestions := ErrorSuggestions(err); !reflect.DeepEqual(suggestions, []string{"listen-addr", "listen-tls"}) {
+		t.Errorf("Got suggestions %q; want %q", suggestions, []string{"listen-addr", "listen-tls"})
+	}
+
+	// Changes to the mapping are seen immediately.
+	delete(parser.Names.Methods, "2fa")
+	names = directiveNames(reflect.TypeOf(ctx), parser.Names)
+	expectNames = []string{"http-url", "listen-addr", "listen-tls", "two-factor"}
+	if !reflect.DeepEqual(names, expectNames) {
+		t.Errorf("directiveNames after change = %q; want %q", names, expectNames)
+	}
+
+	names = directiveNames(reflect.TypeOf(ctx), defaultNames)
+	expectNames = []string{"h-t-t-p-u-r-l", "listen-addr", "listen-t-l-s", "two-factor"}
+	if !reflect.DeepEqual(names, expectNames) {
+		t.Errorf("Default directiveNames = %q; want %q", names, expectNames)
+	}
+}
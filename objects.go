@@ -0,0 +1,341 @@
+package indentfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// ObjectSyntax is the syntax of the object arguments of directives:
+// the arguments starting with "{" or "[",
+// which may span multiple lines.
+//
+// The tokenizer uses the syntax to find the end of each argument,
+// then normalizes the argument to standard JSON,
+// so handlers only ever see JSON.
+type ObjectSyntax interface {
+	// NewScanner returns a scanner for a single object argument.
+	NewScanner() ObjectScanner
+	// Normalize converts the complete source of an object argument
+	// into standard JSON.
+	Normalize(src []byte) ([]byte, error)
+}
+
+// ObjectScanner finds the end of an object argument.
+type ObjectScanner interface {
+	// Scan is called with each byte of the argument in turn,
+	// starting with the opening bracket,
+	// and returns true once given the closing bracket.
+	// A non-nil error stops the tokenizer,
+	// and is reported at the location of the byte.
+	Scan(c byte) (end bool, err error)
+}
+
+// The object syntaxes supported by default.
+var (
+	// JSON is strict JSON, and the default object syntax.
+	JSON ObjectSyntax = jsonSyntax{}
+	// JSON5 is a relaxed JSON syntax,
+	// allowing "//" and "/* */" comments,
+	// trailing commas, unquoted keys and single-quoted strings.
+	JSON5 ObjectSyntax = json5Syntax{}
+)
+
+var objectSyntaxes = map[string]ObjectSyntax{
+	"json":  JSON,
+	"json5": JSON5,
+}
+
+// lookupObjects finds the object syntax named by a file pragma
+// such as "#! indentfile objects=json5".
+func (t *Tokenizer) lookupObjects(name string) ObjectSyntax {
+	if syntax, ok := t.objectSyntaxes[name]; ok {
+		return syntax
+	}
+
+	return objectSyntaxes[name]
+}
+
+type jsonSyntax struct{}
+
+func (jsonSyntax) NewScanner() ObjectScanner {
+	return &jsonScanner{}
+}
+
+func (jsonSyntax) Normalize(src []byte) ([]byte, error) {
+	return src, nil
+}
+
+// jsonScanner matches brackets,
+// ignoring any inside strings.
+type jsonScanner struct {
+	stack   []byte
+	bracket byte
+	escaped bool
+	// The number of open brackets.
+	depth int
+	// The quote characters that can start a string.
+	quotes string
+}
+
+func (s *jsonScanner) Scan(c byte) (bool, error) {
+	if s.quotes == "" {
+		s.quotes = `"`
+	}
+
+	if s.bracket == '"' || s.bracket == '\'' {
+		if c == '\n' {
+			return false, errorAtf(ErrUnquote, LineInfo{},
+				"newline in JSON string")
+		} else if s.escaped {
+			s.escaped = false
+		} else if c == '\\' {
+			s.escaped = true
+		} else if c == s.bracket {
+			s.pop()
+		}
+
+	} else if c == s.bracket {
+		s.depth--
+		if len(s.stack) == 0 {
+			return true, nil
+		}
+
+		s.pop()
+
+	} else if c == '{' {
+		s.depth++
+		s.push('}')
+
+	} else if c == '[' {
+		s.depth++
+		s.push(']')
+
+	} else if bytes.IndexByte([]byte(s.quotes), c) >= 0 {
+		s.push(c)
+	}
+
+	return false, nil
+}
+
+func (s *jsonScanner) push(bracket byte) {
+	if s.bracket != 0 {
+		s.stack = append(s.stack, s.bracket)
+	}
+
+	s.bracket = bracket
+}
+
+func (s *jsonScanner) objectDepth() int {
+	return s.depth
+}
+
+func (s *jsonScanner) pop() {
+	s.bracket = s.stack[len(s.stack)-1]
+	s.stack = s.stack[:len(s.stack)-1]
+}
+
+type json5Syntax struct{}
+
+func (json5Syntax) NewScanner() ObjectScanner {
+	return &json5Scanner{json: jsonScanner{quotes: `"'`}}
+}
+
+// json5Scanner is a jsonScanner that also skips comments.
+type json5Scanner struct {
+	json jsonScanner
+	// The previous byte was a "/" outside any string or comment.
+	slash bool
+	// The previous byte was a "*" inside a block comment.
+	star         bool
+	lineComment  bool
+	blockComment bool
+}
+
+func (s *json5Scanner) Scan(c byte) (bool, error) {
+	if s.lineComment {
+		s.lineComment = c != '\n'
+		return false, nil
+	} else if s.blockComment {
+		s.blockComment = !(s.star && c == '/')
+		s.star = c == '*'
+		return false, nil
+	}
+
+	inString := s.json.bracket == '"' || s.json.bracket == '\''
+	if s.slash && !inString {
+		s.slash = false
+		if c == '/' {
+			s.lineComment = true
+			return false, nil
+		} else if c == '*' {
+			s.blockComment = true
+			return false, nil
+		}
+	}
+
+	s.slash = c == '/' && !inString
+	return s.json.Scan(c)
+}
+
+func (s *json5Scanner) objectDepth() int {
+	return s.json.depth
+}
+
+// Normalize rewrites JSON5 into JSON.
+// Comments and trailing commas are replaced by spaces,
+// unquoted keys are quoted,
+// and single-quoted strings are converted to double-quoted strings.
+// Anything else is left for the JSON decoder to check.
+func (json5Syntax) Normalize(src []byte) ([]byte, error) {
+	out, _, err := json5Syntax{}.normalizeOffsets(src)
+	return out, err
+}
+
+func (json5Syntax) normalizeOffsets(src []byte) ([]byte, []int, error) {
+	n := normalizer{
+		out:     make([]byte, 0, len(src)),
+		offsets: make([]int, 0, len(src)),
+	}
+
+	// The index in out of the last comma,
+	// while it may still be a trailing comma.
+	comma := -1
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+
+			if i < len(src) {
+				n.put(i, '\n')
+			}
+
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				return nil, nil, errorAtf(ErrEOF, LineInfo{},
+					"unclosed comment")
+			}
+
+			n.put(i, ' ')
+			i += end + 3
+
+		case c == '"' || c == '\'':
+			comma = -1
+			n.put(i, '"')
+			for i++; i < len(src) && src[i] != c; i++ {
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+					if src[i] != '\'' {
+						n.put(i-1, '\\')
+					}
+				} else if src[i] == '"' {
+					n.put(i, '\\')
+				}
+
+				n.put(i, src[i])
+			}
+
+			n.put(i, '"')
+
+		case isIdentStart(c):
+			comma = -1
+			start := i
+			for i+1 < len(src) && isIdentPart(src[i+1]) {
+				i++
+			}
+
+			next := bytes.TrimLeft(src[i+1:], " \t\r\n")
+			quote := len(next) > 0 && next[0] == ':'
+			if quote {
+				n.put(start, '"')
+			}
+
+			for j := start; j <= i; j++ {
+				n.put(j, src[j])
+			}
+
+			if quote {
+				n.put(i, '"')
+			}
+
+		case c == ',':
+			comma = len(n.out)
+			n.put(i, c)
+
+		case c == '}' || c == ']':
+			if comma >= 0 {
+				n.out[comma] = ' '
+				comma = -1
+			}
+
+			n.put(i, c)
+
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			n.put(i, c)
+
+		default:
+			comma = -1
+			n.put(i, c)
+		}
+	}
+
+	return n.out, n.offsets, nil
+}
+
+// depthScanner is implemented by object scanners
+// that can report how deeply nested the current byte is,
+// so that TokenizerOptions.MaxObjectDepth can be enforced.
+type depthScanner interface {
+	objectDepth() int
+}
+
+// offsetNormalizer is implemented by object syntaxes
+// that can map each byte of their normalized JSON
+// back to its offset in the source,
+// so that errors can be reported in the right place.
+type offsetNormalizer interface {
+	normalizeOffsets(src []byte) ([]byte, []int, error)
+}
+
+type normalizer struct {
+	out     []byte
+	offsets []int
+}
+
+func (n *normalizer) put(at int, c byte) {
+	n.out = append(n.out, c)
+	n.offsets = append(n.offsets, at)
+}
+
+// validateObject checks that the normalized text of an object argument
+// is valid JSON,
+// reporting any error at its position in the source.
+func validateObject(obj *jsonToken) error {
+	var raw json.RawMessage
+	err := json.Unmarshal(obj.Text(), &raw)
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return errorAtf(ErrJSON, obj.LineInfo(int(syntaxErr.Offset)-1),
+			"%v", syntaxErr)
+	} else if err != nil {
+		return errorAt(err, obj.LineInfo(0))
+	}
+
+	return nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
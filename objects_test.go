@@ -0,0 +1,133 @@
+package indentfile
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type json5Server struct {
+	Name   string `json:"name"`
+	Ports  []int  `json:"ports"`
+	Quoted string `json:"quoted"`
+}
+
+type json5Ctx struct {
+	servers []json5Server
+}
+
+func (c *json5Ctx) Server(server json5Server) {
+	c.servers = append(c.servers, server)
+}
+
+func TestJSON5Pragma(t *testing.T) {
+	ctx := &json5Ctx{}
+	err := ParseFile("test_files/objects/json5.txt", ctx)
+	if err != nil {
+		t.Fatalf("ParseFile returned error: %v", err)
+	}
+
+	expect := []json5Server{{`api "v2"`, []int{80, 443}, "it's"}}
+	if !reflect.DeepEqual(ctx.servers, expect) {
+		t.Errorf("Got %+v; want %+v", ctx.servers, expect)
+	}
+}
+
+func TestJSON5Scanner(t *testing.T) {
+	src := "obj {a: '}', /* } */ b: [1,], // ]\n c: \"'\"}\n"
+	tok := NewTokenizerWithOptions(strings.NewReader(src),
+		TokenizerOptions{Objects: JSON5})
+
+	if _, err := tok.Next(); err != nil {
+		t.Fatalf("Next returned error: %v", err)
+	}
+
+	obj, err := tok.Next()
+	if err != nil {
+		t.Fatalf("Next returned error: %v", err)
+	} else if obj.Type() != ObjectToken {
+		t.Fatalf("Got token type %v; want ObjectToken", obj.Type())
+	}
+
+	var value map[string]interface{}
+	if err := json.Unmarshal(obj.Text(), &value); err != nil {
+		t.Fatalf("Normalized %q is not JSON: %v", obj.Text(), err)
+	}
+
+	expect := map[string]interface{}{
+		"a": "}",
+		"b": []interface{}{1.0},
+		"c": "'",
+	}
+
+	if !reflect.DeepEqual(value, expect) {
+		t.Errorf("Got %v; want %v", value, expect)
+	}
+
+	if info := obj.LineInfo(0); info.Lineno != 1 || info.Offset != 5 {
+		t.Errorf("Got object at %d:%d; want 1:5", info.Lineno, info.Offset)
+	}
+}
+
+func TestUnknownObjectSyntax(t *testing.T) {
+	err := Parse(strings.NewReader("#! indentfile objects=yaml\n"), &json5Ctx{})
+	if !errors.Is(err, ErrPragma) {
+		t.Errorf("Got error %v; want %v", err, ErrPragma)
+	}
+
+	tok := NewTokenizerWithOptions(strings.NewReader("#! indentfile objects=strict\nserver {\"name\": \"a\"}\n"),
+		TokenizerOptions{ObjectSyntaxes: map[string]ObjectSyntax{"strict": JSON}})
+	ctx := &json5Ctx{}
+	if err := ParseTokens(tok, ctx); err != nil {
+		t.Errorf("ParseTokens returned error: %v", err)
+	} else if len(ctx.servers) != 1 || ctx.servers[0].Name != "a" {
+		t.Errorf("Got %+v", ctx.servers)
+	}
+}
+
+func TestObjectErrorLocation(t *testing.T) {
+	err := ParseFile("test_files/objects/invalid.txt", &json5Ctx{})
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("Got error %v; want a JSON syntax error", err)
+	}
+
+	if loc := ErrorLocation(err); loc.Lineno != 3 || loc.Offset != 18 {
+		t.Errorf("Got error at %d:%d; want 3:18", loc.Lineno, loc.Offset)
+	} else if string(loc.Text) != "    \"ports\": [80 443]\n" {
+		t.Errorf("Got error line %q", loc.Text)
+	}
+}
+
+func TestValidateObjects(t *testing.T) {
+	tests := []struct {
+		path           string
+		lineno, offset int
+	}{
+		{"test_files/objects/invalid.txt", 3, 18},
+		{"test_files/objects/invalid5.txt", 6, 1},
+	}
+
+	for _, test := range tests {
+		fd, err := os.Open(test.path)
+		if err != nil {
+			panic(err)
+		}
+
+		tok := NewTokenizerWithOptions(fd, TokenizerOptions{ValidateObjects: true})
+		err = ParseTokens(tok, ArgsHandlerFunc(func(name string, args []Arg) (interface{}, error) {
+			return nil, nil
+		}))
+		fd.Close()
+
+		if !errors.Is(err, ErrJSON) {
+			t.Errorf("%s: got error %v; want %v", test.path, err, ErrJSON)
+		} else if loc := ErrorLocation(err); loc.Lineno != test.lineno || loc.Offset != test.offset {
+			t.Errorf("%s: got error at %d:%d; want %d:%d", test.path,
+				loc.Lineno, loc.Offset, test.lineno, test.offset)
+		}
+	}
+}
@@ -16,14 +16,17 @@ The second argument to these functions is an arbitrary object.
 Provided that this object does not implement DirectiveHandler,
 its set of methods are used as the top-level directives.
 The name of a top-level directive
-is converted from "kebab-case" to "UpperCamelCase".
+is converted from "kebab-case" to "UpperCamelCase",
+or as set by the Names option of a Parser.
 If a method by that name exists -
 and all its parameters are strings -
 then it is called using the directive arguments.
 
-A directive method may have a non-string argument.
-In this case, a JSON argument is required.
-The JSON argument will be unmarshalled into a new instance of that type.
+A directive method may have non-string arguments.
+Each of these requires a JSON argument,
+taken in order from the JSON arguments of the directive,
+while the string parameters take the remaining words in order.
+Each JSON argument will be unmarshalled into a new instance of that type.
 
 The return type of the method determines
 how to proceed with the next directive.
@@ -45,6 +48,24 @@ they should be a context and an error type.
 The error is checked first - as above -
 and the context is used also as described above.
 
+A directive method may also take a Block parameter.
+The method is then only called once the block under the directive
+has been read into a tree of Nodes,
+so that it can inspect every child before acting on them,
+and replay them into any context with Block.Exec.
+Returning a CaptureFunc has the same effect.
+
+If the returned context is a RawBlockHandler,
+such as a RawBlockFunc,
+the block under the directive is not parsed as directives,
+and is instead passed to it as raw text.
+
+A context can accept directives under other names
+by implementing AliasHandler,
+and mark directives as deprecated
+by implementing DeprecationHandler,
+so that a Parser warns when they are used.
+
 See the reflection example for a demonstration of this API.
 
 
@@ -70,7 +91,63 @@ whenever there is no JSON argument,
 but ObjectDirective will be called if a JSON argument is present.
 The source of the JSON argument will be passed
 as a separate argument to the function.
-This argument is suitable to pass directly to json.UnmarshalJSON.
+This argument is suitable to pass directly to json.UnmarshalJSON,
+even when the file uses a relaxed object syntax such as JSON5,
+as the tokenizer normalizes every object argument to standard JSON.
+See ObjectSyntax and TokenizerOptions for the available syntaxes.
+Handlers that keep the raw JSON without decoding it
+can set TokenizerOptions.ValidateObjects
+to have invalid JSON reported as it is read.
+An ObjectDirectiveHandler only accepts one JSON argument.
+
+Rather than switching on the directive name in a DirectiveHandler,
+a Mux can pass each directive to a handler registered for its name,
+checking the number of arguments along the way.
+
+To accept any number of JSON arguments in any position,
+implement the ArgsDirectiveHandler instead.
+Its ArgsDirective method is called for every directive,
+and receives every argument in source order,
+each marked as either a word or JSON.
+
+A block that isn't made of directives,
+such as a table of values,
+can be read straight from the Tokenizer
+by returning a TokenBlockHandler from its directive.
+The Peek, PeekN, Unread and SkipBlock methods of Tokenizer
+help when writing such a block grammar.
+
+By default, a directive that a context does not recognise
+is an error wrapping ErrUnknown.
+A Parser can instead skip such directives,
+report them as warnings,
+collect them into an Unknown field of the context,
+or pass them to a fallback context.
+A context may also handle them itself
+by implementing UnknownDirectiveHandler.
+Errors for unknown directives of reflection contexts
+suggest the most similarly named directives,
+which are also available from ErrorSuggestions.
+Some errors also suggest edits that would fix them,
+which ErrorFixes returns and ApplyFixes applies to the source.
+
+A Parser can also wrap every context it uses in Middleware,
+including the contexts returned for blocks,
+to add behaviour such as logging around every directive.
+Setting a Tracer on a Parser reports each token, directive,
+block and error as it is parsed,
+to help explain how a file was read.
+TraceRecorder records these events,
+and SlogTracer writes them to a log/slog logger.
+
+
+Conditional directives
+
+Wrapping a context with WithConditions
+adds "if", "elif" and "else" directives,
+which select blocks of directives to use
+based on variables and the environment.
+See the documentation of that function for details.
 
 
 Using the Tokenizer API
@@ -82,5 +159,22 @@ See the documentation of that type
 for more details.
 
 
+Using the Document API
+
+When the whole file is needed at once -
+for example in tools that inspect or rewrite configuration -
+ReadDocument and ReadDocumentFile read an indentfile
+into a Document: an in-memory tree of Node values,
+each of which records where in the source it came from.
+
+Directives can be selected from a Document using a Query.
+See the documentation of that type for the query syntax.
+Documents can be layered on top of each other using Merge,
+compared using Diff,
+have their macros expanded using ExpandMacros,
+and a Document can be passed to a context with ParseDocument,
+which follows the same rules as Parse.
+
+
 */
 package indentfile
@@ -0,0 +1,336 @@
+package indentfile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+)
+
+// UnknownPolicy selects what a Parser does with directives
+// that a context does not recognise.
+// A context does not recognise a directive
+// if its handler returns ErrUnknown,
+// either itself or from DirectiveErrorf("%w ...", ErrUnknown).
+// Errors that wrap ErrUnknown more deeply,
+// such as from parsing an included file, are returned as they are.
+type UnknownPolicy int
+
+// The valid UnknownPolicy values.
+const (
+	// Fail with an error wrapping ErrUnknown.
+	UnknownError = UnknownPolicy(iota)
+	// Skip the directive and its block.
+	UnknownSkip
+	// Skip the directive and its block,
+	// passing the ErrUnknown error to Parser.Warn.
+	UnknownWarn
+	// Append the directive and its block
+	// to the context's Unknown field,
+	// which must have type []*Node.
+	// Contexts without such a field are treated as for UnknownWarn.
+	UnknownCollect
+)
+
+// UnknownDirectiveHandler is implemented by contexts
+// that want to handle directives they do not otherwise recognise,
+// taking precedence over the Parser's policy.
+// The returned context is used for the directive's block;
+// if it is nil, the block is skipped.
+type UnknownDirectiveHandler interface {
+	UnknownDirective(name string, args []Arg) (interface{}, error)
+}
+
+// Parser parses indentfiles with options.
+// The zero Parser behaves the same as
+// the Parse, ParseFile, ParseTokens and ParseNodes functions.
+type Parser struct {
+	// The options for the tokenizers created by Parse and ParseFile.
+	Options TokenizerOptions
+	// What to do with directives a context does not recognise.
+	Unknown UnknownPolicy
+	// If not nil, the context that receives directives
+	// the current context does not recognise.
+	// The Unknown policy applies to directives
+	// that neither context recognises.
+	Fallback interface{}
+	// If not nil, called with each warning.
+	Warn func(err error)
+	// If not nil, how the reflection API maps directive names to methods.
+	Names *NameMapping
+	// Wrappers around the handler of every context,
+	// including the contexts returned for blocks.
+	// Each directive passes through them once,
+	// even if UnknownDirective or Fallback handles it.
+	// The first Middleware is the outermost.
+	Middleware []Middleware
+	// If not nil, receives events describing the parse.
+	Tracer Tracer
+
+	// The file being parsed by ParseFile, for tracing.
+	file string
+}
+
+// Middleware wraps the handler of a context,
+// to add behaviour around every directive
+// such as logging or rejecting some directives.
+// next handles the directive as usual,
+// whatever API the context uses.
+type Middleware func(next ArgsDirectiveHandler) ArgsDirectiveHandler
+
+// Parse is like the Parse function,
+// but follows the options of the parser.
+func (p *Parser) Parse(r io.Reader, context interface{}) error {
+	return p.ParseTokens(NewTokenizerWithOptions(r, p.Options), context)
+}
+
+// ParseFile is like the ParseFile function,
+// but follows the options of the parser.
+func (p *Parser) ParseFile(path string, context interface{}) (err error) {
+	var r io.ReadCloser
+	if path == "-" {
+		r = os.Stdin
+		path = "<stdin>"
+	} else {
+		r, err = os.Open(path)
+		if err != nil {
+			return
+		}
+
+		defer r.Close()
+	}
+
+	inFile := *p
+	inFile.file = path
+	if p.Warn != nil {
+		warn := p.Warn
+		inFile.Warn = func(err error) {
+			warn(ErrorInFile(err, path))
+		}
+	}
+
+	tok := NewTokenizerWithOptions(r, p.Options)
+	return inFile.traceError(ErrorInFile(inFile.parseTokens(tok, context, 0), path))
+}
+
+// ParseDocument is like the ParseDocument function,
+// but follows the options of the parser.
+func (p *Parser) ParseDocument(doc *Document, context interface{}) error {
+	return p.ParseNodes(doc.Children, context)
+}
+
+// contextHandler is the handler for a context,
+// wrapped in the parser's middleware.
+type contextHandler struct {
+	ArgsDirectiveHandler
+	// Whether something recognised the last directive,
+	// so that the Unknown policy does not apply.
+	recognised bool
+	// What handled the last directive, when tracing.
+	handledBy string
+}
+
+// handlerFor returns the handler for a context,
+// wrapped in the parser's middleware.
+// The middleware wraps the context's handler
+// together with its UnknownDirective method and the parser's Fallback,
+// so that it sees each directive once, whichever handles it.
+func (p *Parser) handlerFor(context interface{}) *contextHandler {
+	handler := &contextHandler{}
+	inner := getDirectiveHandlerFor(context, p.Names)
+	var fallback ArgsDirectiveHandler
+	if p.Fallback != nil {
+		fallback = getDirectiveHandlerFor(p.Fallback, p.Names)
+	}
+
+	handler.ArgsDirectiveHandler = ArgsHandlerFunc(func(name string, args []Arg) (interface{}, error) {
+		return p.handle(context, inner, fallback, handler, name, args)
+	})
+
+	for i := len(p.Middleware) - 1; i >= 0; i-- {
+		handler.ArgsDirectiveHandler = p.Middleware[i](handler.ArgsDirectiveHandler)
+	}
+
+	return handler
+}
+
+// dispatch passes a directive to handler,
+// applying the parser's options if context does not recognise it,
+// and traces what handled it at the given depth.
+// The node the directive was read from is given if there is one.
+func (p *Parser) dispatch(depth int, context interface{}, handler *contextHandler, line []Token, name string, args []Arg, node *Node) (block interface{}, err error) {
+	// What ended up handling the directive.
+	var handledBy string
+	if p.Tracer != nil {
+		defer func() {
+			p.traceDirective(depth, line, name, args, node, handledBy, err)
+		}()
+	}
+
+	inner := unwrapContext(context)
+	if deprecations, is := inner.(DeprecationHandler); is {
+		replacement, deprecated := deprecations.DeprecatedDirectives()[name]
+		if deprecated {
+			p.warn(locateError(deprecatedError(name, replacement), line), node)
+		}
+	}
+
+	handler.recognised, handler.handledBy = false, "middleware"
+	block, err = handler.ArgsDirective(name, args)
+	handledBy = handler.handledBy
+	if err == nil || handler.recognised || !isUnknown(err) {
+		if err != nil {
+			err = locateError(err, line)
+		}
+
+		return
+	}
+
+	err = locateError(err, line)
+
+	switch p.Unknown {
+	case UnknownSkip:
+		handledBy = "skipped"
+		return skippedBlock{}, nil
+
+	case UnknownCollect:
+		if field := unknownField(inner); field.IsValid() {
+			handledBy = fmt.Sprintf("%T.Unknown", inner)
+			collected := &Node{
+				Name:     name,
+				Args:     args,
+				File:     p.file,
+				Location: line[0].LineInfo(0),
+			}
+
+			if node != nil {
+				collected = node
+			}
+
+			return CaptureFunc(func(block Block) error {
+				collected.Children = block
+				field.Set(reflect.Append(field, reflect.ValueOf(collected)))
+				return nil
+			}), nil
+		}
+
+		fallthrough
+
+	case UnknownWarn:
+		handledBy = "skipped"
+		p.warn(err, node)
+		return skippedBlock{}, nil
+	}
+
+	handledBy = "unknown"
+	return nil, err
+}
+
+// handle passes a directive to the handler for context,
+// then to the context's UnknownDirective method
+// or the parser's Fallback if the context does not recognise it,
+// recording the outcome in handler.
+// If none of them recognise it, the context's error is returned.
+func (p *Parser) handle(context interface{}, inner, fallback ArgsDirectiveHandler, handler *contextHandler, name string, args []Arg) (interface{}, error) {
+	block, err := inner.ArgsDirective(name, args)
+	if err == nil || !isUnknown(err) {
+		handler.recognised = true
+		if p.Tracer != nil {
+			handler.handledBy = p.handlerName(context, name)
+		}
+
+		return block, err
+	}
+
+	unwrapped := unwrapContext(context)
+	if unknown, is := unwrapped.(UnknownDirectiveHandler); is {
+		handler.recognised = true
+		if p.Tracer != nil {
+			handler.handledBy = fmt.Sprintf("%T.UnknownDirective", unwrapped)
+		}
+
+		block, err := unknown.UnknownDirective(name, args)
+		if err == nil && block == nil {
+			block = skippedBlock{}
+		}
+
+		return block, err
+	}
+
+	if fallback != nil {
+		fallbackBlock, fallbackErr := fallback.ArgsDirective(name, args)
+		if fallbackErr == nil || !isUnknown(fallbackErr) {
+			handler.recognised = true
+			if p.Tracer != nil {
+				handler.handledBy = p.handlerName(p.Fallback, name)
+			}
+
+			return fallbackBlock, fallbackErr
+		}
+	}
+
+	return nil, err
+}
+
+// isUnknown reports whether a handler returned err
+// because it does not recognise the directive.
+// Only ErrUnknown itself counts, not errors that merely wrap it,
+// since those come from elsewhere,
+// such as parsing an included file from the directive.
+func isUnknown(err error) bool {
+	if argErr, is := err.(errorArg); is {
+		err = argErr.Err
+	}
+
+	return err == ErrUnknown
+}
+
+// warn passes a located error to the Warn function, if there is one.
+// The node the error was found in is given if there is one.
+func (p *Parser) warn(err error, node *Node) {
+	if p.Warn == nil {
+		return
+	}
+
+	if node != nil {
+		err = node.errorInFile(err)
+	}
+
+	p.Warn(err)
+}
+
+// contextWrapper is implemented by handlers that wrap a context,
+// such as the one returned by WithConditions,
+// so that the parser still finds the interfaces the context implements.
+type contextWrapper interface {
+	wrappedContext() interface{}
+}
+
+// unwrapContext returns the context that context wraps, if any.
+func unwrapContext(context interface{}) interface{} {
+	for {
+		wrapper, is := context.(contextWrapper)
+		if !is {
+			return context
+		}
+
+		context = wrapper.wrappedContext()
+	}
+}
+
+// unknownField finds the Unknown field of a context,
+// if it has one that UnknownCollect can use.
+func unknownField(context interface{}) reflect.Value {
+	value := reflect.ValueOf(context)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+
+	field := value.Elem().FieldByName("Unknown")
+	if !field.IsValid() || !field.CanSet() ||
+		field.Type() != reflect.TypeOf([]*Node(nil)) {
+		return reflect.Value{}
+	}
+
+	return field
+}
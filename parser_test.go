@@ -0,0 +1,194 @@
+package indentfile
+
+import (
+	"container/list"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type collectCtx struct {
+	msgCtx
+	Unknown []*Node
+}
+
+type unknownCtx struct {
+	msgCtx
+}
+
+func (c *unknownCtx) UnknownDirective(name string, args []Arg) (interface{}, error) {
+	c.Msg("unknown", name)
+	return nil, nil
+}
+
+func TestUnknownPolicy(t *testing.T) {
+	const path = "test_files/parse/unknown.txt"
+	expect := []string{"known", "after", "<end>"}
+
+	checkMessages := func(name string, messages *list.List) {
+		node := messages.Front()
+		for i, msg := range expect {
+			if node == nil || node.Value.(string) != msg {
+				t.Errorf("%s: got %d = %v; want %q", name, i, node, msg)
+				return
+			}
+
+			node = node.Next()
+		}
+	}
+
+	err := (&Parser{}).ParseFile(path, &msgCtx{list.New(), ""})
+	if !errors.Is(err, ErrUnknown) {
+		t.Errorf("Default policy got error %v; want %v", err, ErrUnknown)
+	} else if loc := ErrorLocation(err); loc.Lineno != 2 {
+		t.Errorf("Default policy got error on line %d; want 2", loc.Lineno)
+	}
+
+	ctx := &msgCtx{list.New(), ""}
+	err = (&Parser{Unknown: UnknownSkip}).ParseFile(path, ctx)
+	if err != nil {
+		t.Errorf("UnknownSkip returned error: %v", err)
+	}
+
+	checkMessages("UnknownSkip", ctx.messages)
+
+	var warnings []error
+	ctx = &msgCtx{list.New(), ""}
+	err = (&Parser{Unknown: UnknownWarn, Warn: func(err error) {
+		warnings = append(warnings, err)
+	}}).ParseFile(path, ctx)
+	if err != nil {
+		t.Errorf("UnknownWarn returned error: %v", err)
+	} else if len(warnings) != 1 || !errors.Is(warnings[0], ErrUnknown) {
+		t.Errorf("UnknownWarn got warnings %v", warnings)
+	} else if msg := warnings[0].Error(); msg != `unknown directive in file `+path+` (2:1): "future-thing"` {
+		t.Errorf("UnknownWarn got warning %q", msg)
+	}
+
+	checkMessages("UnknownWarn", ctx.messages)
+
+	doc, err := ReadDocumentFile(path)
+	if err != nil {
+		t.Fatalf("ReadDocumentFile returned error: %v", err)
+	}
+
+	for name, parse := range map[string]func(p *Parser, ctx interface{}) error{
+		"ParseFile": func(p *Parser, ctx interface{}) error {
+			return p.ParseFile(path, ctx)
+		},
+		"ParseDocument": func(p *Parser, ctx interface{}) error {
+			return p.ParseDocument(doc, ctx)
+		},
+	} {
+		collect := &collectCtx{msgCtx: msgCtx{list.New(), ""}}
+		err = parse(&Parser{Unknown: UnknownCollect}, collect)
+		if err != nil {
+			t.Errorf("UnknownCollect %s returned error: %v", name, err)
+		} else if len(collect.Unknown) != 1 ||
+			collect.Unknown[0].String() != "future-thing a b" ||
+			len(collect.Unknown[0].Children) != 1 ||
+			collect.Unknown[0].Children[0].String() != "nested stuff" {
+			t.Errorf("UnknownCollect %s got %v", name, collect.Unknown)
+		} else if file := collect.Unknown[0].File; file != path {
+			t.Errorf("UnknownCollect %s got file %q; want %q", name, file, path)
+		}
+
+		checkMessages("UnknownCollect "+name, collect.messages)
+	}
+
+	var fallback []string
+	ctx = &msgCtx{list.New(), ""}
+	err = (&Parser{Fallback: HandlerFunc(func(name string, argv []string) (interface{}, error) {
+		fallback = append(fallback, name)
+		return skippedBlock{}, nil
+	})}).ParseFile(path, ctx)
+	if err != nil {
+		t.Errorf("Fallback returned error: %v", err)
+	} else if len(fallback) != 1 || fallback[0] != "future-thing" {
+		t.Errorf("Fallback got %v", fallback)
+	}
+
+	checkMessages("Fallback", ctx.messages)
+
+	fallback = nil
+	rejecting := HandlerFunc(func(name string, argv []string) (interface{}, error) {
+		fallback = append(fallback, name)
+		return nil, ErrUnknown
+	})
+
+	ctx = &msgCtx{list.New(), ""}
+	err = (&Parser{Fallback: rejecting, Unknown: UnknownSkip}).ParseFile(path, ctx)
+	if err != nil {
+		t.Errorf("Fallback with UnknownSkip returned error: %v", err)
+	} else if len(fallback) != 1 || fallback[0] != "future-thing" {
+		t.Errorf("Fallback with UnknownSkip got %v", fallback)
+	}
+
+	checkMessages("Fallback with UnknownSkip", ctx.messages)
+
+	collect := &collectCtx{msgCtx: msgCtx{list.New(), ""}}
+	err = (&Parser{Fallback: rejecting, Unknown: UnknownCollect}).ParseFile(path, collect)
+	if err != nil {
+		t.Errorf("Fallback with UnknownCollect returned error: %v", err)
+	} else if len(collect.Unknown) != 1 || collect.Unknown[0].String() != "future-thing a b" {
+		t.Errorf("Fallback with UnknownCollect got %v", collect.Unknown)
+	}
+
+	checkMessages("Fallback with UnknownCollect", collect.messages)
+
+	ctx = &msgCtx{list.New(), ""}
+	err = (&Parser{Fallback: rejecting}).ParseFile(path, ctx)
+	if !errors.Is(err, ErrUnknown) {
+		t.Errorf("Fallback with UnknownError got error %v; want %v", err, ErrUnknown)
+	} else if loc := ErrorLocation(err); loc.Lineno != 2 {
+		t.Errorf("Fallback with UnknownError got error on line %d; want 2", loc.Lineno)
+	}
+
+	unknown := &unknownCtx{msgCtx{list.New(), ""}}
+	err = (&Parser{}).ParseFile(path, unknown)
+	if err != nil {
+		t.Errorf("UnknownDirective returned error: %v", err)
+	}
+
+	expect = []string{"known", "unknown future-thing", "after", "<end>"}
+	checkMessages("UnknownDirective", unknown.messages)
+}
+
+type nestedCtx struct {
+	msgCtx
+}
+
+func (c *nestedCtx) Nested() error {
+	return Parse(strings.NewReader("msg inner\nbogus\n"), c.msgCtx)
+}
+
+func TestUnknownPolicyNested(t *testing.T) {
+	var warnings []error
+	fallback := HandlerFunc(func(name string, argv []string) (interface{}, error) {
+		t.Errorf("Fallback called for %q", name)
+		return nil, nil
+	})
+
+	for _, p := range []*Parser{
+		{Unknown: UnknownSkip},
+		{Unknown: UnknownWarn},
+		{Unknown: UnknownCollect},
+		{Fallback: fallback},
+	} {
+		p.Warn = func(err error) { warnings = append(warnings, err) }
+
+		// The unknown directive is in the nested parse,
+		// so the outer parser's policy does not apply to it.
+		ctx := &nestedCtx{msgCtx{list.New(), ""}}
+		err := p.Parse(strings.NewReader("nested\nmsg after\n"), ctx)
+		if !errors.Is(err, ErrUnknown) {
+			t.Errorf("Policy %d got error %v; want %v", p.Unknown, err, ErrUnknown)
+		} else if loc := ErrorLocation(err); loc.Lineno != 1 {
+			t.Errorf("Policy %d got error on line %d; want 1", p.Unknown, loc.Lineno)
+		}
+	}
+
+	if len(warnings) != 0 {
+		t.Errorf("Got warnings %v", warnings)
+	}
+}
@@ -0,0 +1,158 @@
+package indentfile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// Pragma is a comment that carries metadata rather than commentary.
+//
+// A comment starting with "#!" is a file-level pragma,
+// and applies to the whole file wherever it appears.
+// A comment starting with "#@" is a directive pragma,
+// and applies to the next directive in the file.
+// A directive pragma at the end of a block, indented as part of it,
+// is ignored rather than applying to the directive after the block.
+// The rest of the comment is split into words in the same way
+// as a directive, for example:
+//
+//	#! indentfile version=2
+//	#@ deprecated since="version 2"
+//	old-directive
+//
+// The first word is the pragma name,
+// words containing "=" are parameters,
+// and any other words are arguments.
+// A comment that can't be split into words, or has no name,
+// is not a pragma,
+// unless TokenizerOptions.StrictPragmas is set.
+type Pragma struct {
+	// The name of the pragma.
+	Name string
+	// Words after the name that are not parameters.
+	Args []string
+	// Words after the name of the form key=value.
+	Params map[string]string
+	// The location of the comment.
+	Location LineInfo
+}
+
+// PragmaHandler is implemented by contexts that want the pragmas
+// attached to their directives.
+// DirectivePragmas is called immediately before each directive
+// that has any "#@" pragmas.
+type PragmaHandler interface {
+	DirectivePragmas(name string, pragmas []Pragma) error
+}
+
+// FilePragmas returns every file-level pragma read so far.
+func (t *Tokenizer) FilePragmas() []Pragma {
+	return t.filePragmas
+}
+
+// TakePragmas returns every directive pragma read
+// since TakePragmas was last called.
+// Call it when reaching the end of a directive
+// to find the pragmas attached to that directive.
+func (t *Tokenizer) TakePragmas() (pragmas []Pragma) {
+	pragmas = t.pragmas
+	t.pragmas = nil
+	return
+}
+
+func (t *Tokenizer) readPragma(comment Token) error {
+	text := comment.Text()
+	if !bytes.HasPrefix(text, []byte("#!")) && !bytes.HasPrefix(text, []byte("#@")) {
+		return nil
+	}
+
+	info := comment.LineInfo(0)
+	pragma := Pragma{Location: info}
+
+	// Restore the newline, so that unclosed quotes are noticed.
+	source := append([]byte(nil), bytes.TrimLeft(text[2:], " \t")...)
+	source = append(source, '\n')
+	words := NewTokenizer(bytes.NewReader(source))
+	for {
+		word, err := words.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return t.malformedPragma(errorAtf(ErrPragma, info, "%v", errors.Unwrap(err)))
+		} else if word.Type() != WordToken {
+			continue
+		}
+
+		if pragma.Name == "" {
+			pragma.Name = string(word.Text())
+		} else if eq := bytes.IndexByte(word.Text(), '='); eq > 0 {
+			if pragma.Params == nil {
+				pragma.Params = make(map[string]string)
+			}
+
+			pragma.Params[string(word.Text()[:eq])] = string(word.Text()[eq+1:])
+		} else {
+			pragma.Args = append(pragma.Args, string(word.Text()))
+		}
+	}
+
+	if pragma.Name == "" {
+		return t.malformedPragma(errorAtf(ErrPragma, info, "missing pragma name"))
+	}
+
+	if text[1] == '!' {
+		t.filePragmas = append(t.filePragmas, pragma)
+		if objects, ok := pragma.Params["objects"]; ok && pragma.Name == "indentfile" {
+			t.objects = t.lookupObjects(objects)
+			if t.objects == nil {
+				return errorAtf(ErrPragma, info,
+					"unknown object syntax %q", objects)
+			}
+		}
+	} else {
+		t.pragmas = append(t.pragmas, pragma)
+	}
+
+	return nil
+}
+
+// dropBlockPragmas discards the directive pragmas
+// indented further than indent.
+// They were written at the end of a block that has just closed,
+// so there is no directive left for them to apply to.
+func (t *Tokenizer) dropBlockPragmas(indent []byte) {
+	kept := t.pragmas[:0]
+	for _, pragma := range t.pragmas {
+		if pragma.Location.Offset-1 <= len(indent) {
+			kept = append(kept, pragma)
+		}
+	}
+
+	t.pragmas = kept
+}
+
+// malformedPragma returns err if pragmas are strict,
+// and otherwise ignores it,
+// since the comment may never have been meant as a pragma.
+func (t *Tokenizer) malformedPragma(err error) error {
+	if t.limits.StrictPragmas {
+		return err
+	}
+
+	return nil
+}
+
+func deliverPragmas(context interface{}, name string, pragmas []Pragma) error {
+	if len(pragmas) == 0 {
+		return nil
+	}
+
+	if handler, is := unwrapContext(context).(PragmaHandler); is {
+		if err := handler.DirectivePragmas(name, pragmas); err != nil {
+			return DirectiveErrorf("%w", err)
+		}
+	}
+
+	return nil
+}
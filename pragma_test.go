@@ -0,0 +1,126 @@
+package indentfile
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPragmas(t *testing.T) {
+	fd, err := os.Open("test_files/pragma/pragmas.txt")
+	if err != nil {
+		panic(err)
+	}
+
+	defer fd.Close()
+
+	tok := NewTokenizer(fd)
+	ctx := &pragmaCtx{msgCtx: msgCtx{list.New(), ""}}
+	err = ParseTokens(tok, ctx)
+	if err != nil {
+		t.Fatalf("ParseTokens returned error: %v", err)
+	}
+
+	expect := []string{
+		`msg deprecated [loudly] map[since:version 2] at 3:1`,
+		// The pragma at the end of the block
+		// must not leak out to the next directive.
+		`msg stable [] map[] at 9:1`,
+	}
+
+	if !reflect.DeepEqual(ctx.seen, expect) {
+		t.Errorf("Got pragmas %q; want %q", ctx.seen, expect)
+	}
+
+	filePragmas := tok.FilePragmas()
+	if len(filePragmas) != 2 ||
+		filePragmas[0].Name != "indentfile" ||
+		filePragmas[0].Params["version"] != "2" ||
+		filePragmas[1].Name != "generated" {
+		t.Errorf("Got file pragmas %v", filePragmas)
+	}
+}
+
+func TestDocumentPragmas(t *testing.T) {
+	doc, err := ReadDocumentFile("test_files/pragma/pragmas.txt")
+	if err != nil {
+		t.Fatalf("ReadDocumentFile returned error: %v", err)
+	}
+
+	if len(doc.Pragmas) != 2 {
+		t.Errorf("Got %d file pragmas; want 2", len(doc.Pragmas))
+	}
+
+	for i, expect := range []int{1, 0, 1, 0} {
+		if len(doc.Children[i].Pragmas) != expect {
+			t.Errorf("Directive %d has %d pragmas; want %d",
+				i, len(doc.Children[i].Pragmas), expect)
+		}
+	}
+
+	ctx := &pragmaCtx{msgCtx: msgCtx{list.New(), ""}}
+	err = ParseDocument(doc, ctx)
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %v", err)
+	}
+
+	if len(ctx.seen) != 2 {
+		t.Errorf("Got %d pragma deliveries; want 2", len(ctx.seen))
+	}
+}
+
+func TestPragmaErrors(t *testing.T) {
+	for _, source := range []string{
+		"#@ \"unclosed\nmsg hi\n",
+		"#@\nmsg hi\n",
+		"#!/usr/bin/env \"foo\nmsg hi\n",
+		"#!\nmsg hi\n",
+	} {
+		ctx := &pragmaCtx{msgCtx: msgCtx{list.New(), ""}}
+		tok := NewTokenizer(strings.NewReader(source))
+		err := ParseTokens(tok, ctx)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", source, err)
+		} else if len(ctx.seen) != 0 || len(tok.FilePragmas()) != 0 {
+			t.Errorf("Parse(%q) read pragmas %q and %v",
+				source, ctx.seen, tok.FilePragmas())
+		}
+
+		tok = NewTokenizerWithOptions(strings.NewReader(source),
+			TokenizerOptions{StrictPragmas: true})
+		err = ParseTokens(tok, &msgCtx{list.New(), ""})
+		if !errors.Is(err, ErrPragma) {
+			t.Errorf("Strict Parse(%q) error = %v; want ErrPragma", source, err)
+		}
+	}
+
+	ctx := &pragmaCtx{msgCtx: msgCtx{list.New(), ""}, reject: true}
+	err := Parse(strings.NewReader("msg hi\n#@ nope\nmsg there\n"), ctx)
+	if !errors.Is(err, ErrDirective) || ErrorLocation(err).Lineno != 3 {
+		t.Errorf("Parse error = %v; want ErrDirective at line 3", err)
+	}
+}
+
+type pragmaCtx struct {
+	msgCtx
+	seen   []string
+	reject bool
+}
+
+func (ctx *pragmaCtx) DirectivePragmas(name string, pragmas []Pragma) error {
+	if ctx.reject {
+		return errors.New("pragmas not allowed")
+	}
+
+	for _, p := range pragmas {
+		ctx.seen = append(ctx.seen, fmt.Sprintf("%s %s %v %v at %d:%d",
+			name, p.Name, p.Args, p.Params,
+			p.Location.Lineno, p.Location.Offset))
+	}
+
+	return nil
+}
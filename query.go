@@ -0,0 +1,399 @@
+package indentfile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrQuery is the error returned when a query fails to compile.
+var ErrQuery = errorWrap("bad query", nil)
+
+// Query is a compiled selector for directives in a Document.
+//
+// A query is a sequence of steps separated by "/",
+// each matching the name of a directive
+// one block below the directives matched by the previous step.
+// A step separated by "//" instead matches at any depth below.
+// A leading "//" starts the search at any depth in the document.
+//
+// Names may contain the wildcards "*" and "?",
+// and a backslash escapes the next character.
+// Each step may be followed by any number of predicates in brackets:
+//
+//	server[api]        the first argument matches "api"
+//	listen[2=80]       the second argument matches "80"
+//	listen[*=80]       any argument matches "80"
+//	route[#3]          there are exactly 3 arguments
+//
+// Predicate values may use the same wildcards,
+// and may be quoted like a directive argument.
+// For example, "//server[api*]/listen" selects
+// every listen directive directly under
+// any server directive whose first argument starts with "api".
+type Query struct {
+	src   string
+	steps []queryStep
+}
+
+type queryStep struct {
+	descendant bool
+	name       string
+	preds      []queryPred
+}
+
+type queryPred struct {
+	// index is the 1-based argument index,
+	// 0 for any argument,
+	// or -1 to compare the number of arguments.
+	index   int
+	pattern string
+	count   int
+}
+
+// CompileQuery parses a query, returning an error wrapping ErrQuery
+// if the syntax is invalid.
+func CompileQuery(src string) (*Query, error) {
+	q := &Query{src: src}
+	p := queryParser{src: src}
+
+	if p.consume("//") {
+		p.descendant = true
+	} else {
+		p.consume("/")
+	}
+
+	for {
+		step, err := p.step()
+		if err != nil {
+			return nil, err
+		}
+
+		q.steps = append(q.steps, step)
+
+		if p.done() {
+			break
+		} else if p.consume("//") {
+			p.descendant = true
+		} else if !p.consume("/") {
+			return nil, p.errorf("expected /")
+		}
+	}
+
+	return q, nil
+}
+
+// MustCompileQuery is like CompileQuery but panics on error.
+func MustCompileQuery(src string) *Query {
+	q, err := CompileQuery(src)
+	if err != nil {
+		panic(err)
+	}
+
+	return q
+}
+
+// String returns the source of the query.
+func (q *Query) String() string {
+	return q.src
+}
+
+// Find returns every directive in doc matched by the query,
+// in document order.
+func (q *Query) Find(doc *Document) []*Node {
+	return q.FindNodes(doc.Children)
+}
+
+// FindNodes is like Find,
+// but treats nodes as the top-level directives.
+func (q *Query) FindNodes(nodes []*Node) []*Node {
+	current := []*Node{{Children: nodes}}
+
+	for _, step := range q.steps {
+		seen := make(map[*Node]bool)
+		var next []*Node
+		var visit func(nodes []*Node)
+		visit = func(nodes []*Node) {
+			for _, node := range nodes {
+				if !seen[node] && step.matches(node) {
+					seen[node] = true
+					next = append(next, node)
+				}
+
+				if step.descendant {
+					visit(node.Children)
+				}
+			}
+		}
+
+		for _, node := range current {
+			visit(node.Children)
+		}
+
+		current = next
+	}
+
+	return current
+}
+
+// Query compiles src and returns every directive it matches.
+func (d *Document) Query(src string) ([]*Node, error) {
+	q, err := CompileQuery(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.Find(d), nil
+}
+
+func (step *queryStep) matches(node *Node) bool {
+	if !globMatch(step.name, node.Name) {
+		return false
+	}
+
+	for _, pred := range step.preds {
+		if !pred.matches(node) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (pred *queryPred) matches(node *Node) bool {
+	if pred.index < 0 {
+		return len(node.Args) == pred.count
+	}
+
+	if pred.index > 0 {
+		return pred.index <= len(node.Args) &&
+			globMatch(pred.pattern, node.Args[pred.index-1].Text)
+	}
+
+	for _, arg := range node.Args {
+		if globMatch(pred.pattern, arg.Text) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type queryParser struct {
+	src        string
+	at         int
+	descendant bool
+}
+
+func (p *queryParser) done() bool {
+	return p.at >= len(p.src)
+}
+
+func (p *queryParser) consume(prefix string) bool {
+	if strings.HasPrefix(p.src[p.at:], prefix) {
+		p.at += len(prefix)
+		return true
+	}
+
+	return false
+}
+
+func (p *queryParser) errorf(format string, v ...interface{}) error {
+	return fmt.Errorf("%w at offset %d: %s",
+		ErrQuery, p.at+1, fmt.Sprintf(format, v...))
+}
+
+func (p *queryParser) step() (step queryStep, err error) {
+	step.descendant = p.descendant
+	p.descendant = false
+
+	step.name, err = p.pattern("/[]")
+	if err != nil {
+		return
+	} else if step.name == "" {
+		return step, p.errorf("expected directive name")
+	}
+
+	for p.consume("[") {
+		var pred queryPred
+		pred, err = p.pred()
+		if err != nil {
+			return
+		}
+
+		if !p.consume("]") {
+			return step, p.errorf("expected ]")
+		}
+
+		step.preds = append(step.preds, pred)
+	}
+
+	return
+}
+
+func (p *queryParser) pred() (pred queryPred, err error) {
+	pred.index = 1
+
+	if p.consume("#") {
+		end := p.indexUnquoted(']', 0)
+		if end < 0 {
+			return pred, p.errorf("expected ]")
+		}
+
+		pred.index = -1
+		pred.count, err = strconv.Atoi(p.src[p.at : p.at+end])
+		if err != nil || pred.count < 0 {
+			return pred, p.errorf("bad argument count")
+		}
+
+		p.at += end
+		return
+	}
+
+	if eq := p.indexUnquoted('=', ']'); eq > 0 {
+		prefix := p.src[p.at : p.at+eq]
+		if prefix == "*" {
+			pred.index = 0
+			p.at += eq + 1
+		} else if n, convErr := strconv.Atoi(prefix); convErr == nil {
+			if n < 1 {
+				return pred, p.errorf("argument index must be at least 1")
+			}
+
+			pred.index = n
+			p.at += eq + 1
+		}
+	}
+
+	pred.pattern, err = p.pattern("]")
+	return
+}
+
+// indexUnquoted returns the offset from the current position
+// of the first c outside quotes and not escaped,
+// or -1 if there is none before stop or the end of the query.
+func (p *queryParser) indexUnquoted(c, stop byte) int {
+	var quote byte
+	for i := p.at; i < len(p.src); i++ {
+		switch ch := p.src[i]; {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+
+		case ch == c:
+			return i - p.at
+
+		case ch == stop:
+			return -1
+
+		case ch == '"' || ch == '\'':
+			quote = ch
+
+		case ch == '\\':
+			i++
+		}
+	}
+
+	return -1
+}
+
+// pattern reads a possibly-quoted pattern up to one of the stop characters.
+// Quoted text has any wildcards in it escaped.
+func (p *queryParser) pattern(stop string) (string, error) {
+	var b strings.Builder
+	var quote byte
+
+	for ; !p.done(); p.at++ {
+		c := p.src[p.at]
+
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			} else {
+				if c == '*' || c == '?' || c == '\\' {
+					b.WriteByte('\\')
+				}
+
+				b.WriteByte(c)
+			}
+
+		} else if c == '"' || c == '\'' {
+			quote = c
+
+		} else if c == '\\' {
+			if p.at+1 == len(p.src) {
+				return "", p.errorf("trailing backslash")
+			}
+
+			b.WriteByte(c)
+			p.at++
+			b.WriteByte(p.src[p.at])
+
+		} else if strings.IndexByte(stop, c) >= 0 {
+			break
+
+		} else {
+			b.WriteByte(c)
+		}
+	}
+
+	if quote != 0 {
+		return "", p.errorf("unclosed quotes")
+	}
+
+	return b.String(), nil
+}
+
+// globMatch reports whether s matches pattern,
+// where "*" matches any sequence of bytes,
+// "?" matches any single byte,
+// and a backslash escapes the following byte.
+func globMatch(pattern, s string) bool {
+	// On a mismatch, only the most recent "*" needs to match more,
+	// since any earlier one could only move the same match later.
+	// This keeps matching linear in the length of s for each "*".
+	px, sx := 0, 0
+	starPx, starSx := -1, 0
+	for px < len(pattern) || sx < len(s) {
+		if px < len(pattern) {
+			c, width := pattern[px], 1
+			switch c {
+			case '*':
+				starPx, starSx = px, sx
+				px++
+				continue
+
+			case '?':
+				if sx < len(s) {
+					px++
+					sx++
+					continue
+				}
+
+			case '\\':
+				if px+1 < len(pattern) {
+					c, width = pattern[px+1], 2
+				}
+
+				fallthrough
+
+			default:
+				if sx < len(s) && s[sx] == c {
+					px += width
+					sx++
+					continue
+				}
+			}
+		}
+
+		if starPx < 0 || starSx == len(s) {
+			return false
+		}
+
+		starSx++
+		px, sx = starPx+1, starSx
+	}
+
+	return true
+}
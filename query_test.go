@@ -0,0 +1,123 @@
+package indentfile
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	doc, err := ReadDocumentFile("test_files/document/servers.txt")
+	if err != nil {
+		t.Fatalf("ReadDocumentFile returned error: %v", err)
+	}
+
+	cases := []struct {
+		query  string
+		expect []int
+	}{
+		{"server[api]/listen", []int{4, 5}},
+		{"/server[api*]/listen", []int{4, 5, 9}},
+		{"server['api*']/listen", nil},
+		{"server/listen[80]", []int{4, 12}},
+		{"server/listen[2=tls]", []int{5}},
+		{"server/*[*=tls]", []int{5}},
+		{"server/*[#2]", []int{5, 6}},
+		{"//listen", []int{4, 5, 9, 12, 14}},
+		{"server[web]//listen", []int{12, 14}},
+		{"//static//listen", []int{14}},
+		{"//route[1=/v1]", []int{6}},
+		{"server[\"api internal\"]/listen[8???]", []int{9}},
+		{"listen", nil},
+	}
+
+	for _, c := range cases {
+		nodes, err := doc.Query(c.query)
+		if err != nil {
+			t.Errorf("Query(%q) returned error: %v", c.query, err)
+			continue
+		}
+
+		if len(nodes) != len(c.expect) {
+			t.Errorf("Query(%q) got %d nodes; want %d",
+				c.query, len(nodes), len(c.expect))
+			continue
+		}
+
+		for i, node := range nodes {
+			if node.Location.Lineno != c.expect[i] {
+				t.Errorf("Query(%q) result %d at line %d; want %d",
+					c.query, i, node.Location.Lineno, c.expect[i])
+			}
+		}
+	}
+}
+
+func TestQueryQuotedPredicates(t *testing.T) {
+	doc, err := ReadDocument(strings.NewReader("route \"a]b\" \"1=2\"\n"))
+	if err != nil {
+		t.Fatalf("ReadDocument returned error: %v", err)
+	}
+
+	for query, match := range map[string]bool{
+		`route["a]b"]`:      true,
+		`route[1='a]b']`:    true,
+		`route[2="1=2"]`:    true,
+		`route[*='1=2']`:    true,
+		`route["1=2"]`:      false,
+		`route['2=a]b']`:    false,
+		`route[*="a]b"][2]`: false,
+	} {
+		nodes, err := doc.Query(query)
+		if err != nil {
+			t.Errorf("Query(%q) returned error: %v", query, err)
+		} else if (len(nodes) == 1) != match {
+			t.Errorf("Query(%q) got %d nodes; want match %v", query, len(nodes), match)
+		}
+	}
+}
+
+func TestQuerySyntaxErrors(t *testing.T) {
+	for _, query := range []string{
+		"",
+		"server/",
+		"server[api",
+		"server[#x]",
+		"server[0=api]",
+		"server['api]",
+		"server]",
+	} {
+		_, err := CompileQuery(query)
+		if !errors.Is(err, ErrQuery) {
+			t.Errorf("CompileQuery(%q) error = %v; want ErrQuery", query, err)
+		}
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	cases := []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"abc", "abc", true},
+		{"a*", "a/b/c", true},
+		{"*c", "abc", true},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{`a\*`, "a*", true},
+		{`a\*`, "ab", false},
+		{"*", "", true},
+		{"a*b*c", "aXbYbc", true},
+		{"a*b*c", "aXbYb", false},
+		{`\`, `\`, true},
+		{"*a*a*a*a*a*a*a*a*a*a*a*a*b", strings.Repeat("a", 64), false},
+		{"*a*a*a*a*a*a*a*a*a*a*a*a*b", strings.Repeat("a", 64) + "b", true},
+	}
+
+	for _, c := range cases {
+		if globMatch(c.pattern, c.s) != c.match {
+			t.Errorf("globMatch(%q, %q) = %v; want %v",
+				c.pattern, c.s, !c.match, c.match)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package indentfile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// RawBlockHandler is implemented by directive contexts
+// whose block is free text rather than directives,
+// such as a script or a certificate.
+//
+// When a directive returns a RawBlockHandler
+// and is followed by an indented block,
+// ParseTokens reads the block with NextRawBlock
+// and passes the resulting TextToken to RawBlock.
+// Quotes and "#" in the block have no special meaning.
+// Errors without a location are reported at the start of the block;
+// use ErrorAt with LineInfo on the token to locate errors within it.
+//
+// ReadDocument cannot know which blocks are raw,
+// so raw blocks are only supported by ParseTokens.
+type RawBlockHandler interface {
+	RawBlock(text Token) error
+}
+
+// RawBlockFunc is a RawBlockHandler.
+// A directive method using the reflection API
+// can return a RawBlockFunc to accept a raw block.
+type RawBlockFunc func(text Token) error
+
+func (fn RawBlockFunc) RawBlock(text Token) error {
+	return fn(text)
+}
+
+// NextRawBlock reads the rest of the block that has just been indented
+// as one TextToken, without tokenizing it.
+// It must be called immediately after Next returns an IndentToken.
+// Blank lines inside the block are kept,
+// but blank lines at its end are not.
+// The OutdentToken ending the block is consumed as well.
+func (t *Tokenizer) NextRawBlock() (Token, error) {
+	if t.lastToken != IndentToken || len(t.lookahead) > 0 {
+		return nil, errorAtf(ErrToken, t.info(),
+			"raw block must start just after an indent")
+	}
+
+	indent := t.indentStack.Back().Value.([]byte)
+	text := &textToken{}
+	var blank []int
+
+	for {
+		var err error
+		if t.line == nil {
+			err = t.readLine()
+			if err != nil && !errors.Is(err, io.EOF) {
+				return nil, err
+			}
+		}
+
+		if len(bytes.TrimRight(t.line, " \t\r\n")) == 0 {
+			if len(t.line) == 0 {
+				// Reached the end of the file.
+				t.line = nil
+				break
+			}
+
+			blank = append(blank, t.lineno)
+		} else if bytes.HasPrefix(t.line, indent) {
+			for _, lineno := range blank {
+				text.add([]byte{'\n'}, lineno, 1, nil)
+			}
+
+			blank = nil
+			text.add(t.line[len(indent):], t.lineno, len(indent)+1, t.line)
+		} else {
+			// This line is outdented, so leave it to be tokenized.
+			t.offset = 1
+			break
+		}
+
+		t.line = nil
+		if err != nil {
+			break
+		}
+	}
+
+	t.lastToken = TerminatorToken
+	outdent, err := t.next()
+	if err != nil {
+		return nil, err
+	} else if outdent.Type() != OutdentToken {
+		return nil, errorAt(ErrOutdent, outdent.LineInfo(0))
+	}
+
+	return text, nil
+}
+
+type textToken struct {
+	text      []byte
+	charstops []charstop
+	lines     [][]byte
+}
+
+func (t *textToken) add(line []byte, lineno, offset int, source []byte) {
+	t.charstops = append(t.charstops, charstop{len(t.text), lineno, offset})
+	t.lines = append(t.lines, source)
+	t.text = append(t.text, line...)
+}
+
+func (t *textToken) Type() TokenType {
+	return TextToken
+}
+
+func (t *textToken) LineInfo(at int) LineInfo {
+	for i := len(t.charstops) - 1; i >= 0; i-- {
+		stop := t.charstops[i]
+		if stop.at <= at || i == 0 {
+			return LineInfo{
+				stop.lineno,
+				stop.offset + (at - stop.at),
+				t.lines[i],
+			}
+		}
+	}
+
+	return LineInfo{-1, -1, nil}
+}
+
+func (t *textToken) Text() []byte {
+	return t.text
+}
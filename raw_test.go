@@ -0,0 +1,54 @@
+package indentfile
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type rawCtx struct {
+	msgCtx
+	scripts []string
+}
+
+var errBadScript = errors.New("bad script")
+
+func (c *rawCtx) Script(lang string) RawBlockFunc {
+	return func(text Token) error {
+		if at := bytes.Index(text.Text(), []byte("fail")); at >= 0 {
+			return ErrorAt(errBadScript, text.LineInfo(at))
+		}
+
+		c.scripts = append(c.scripts, lang+":"+string(text.Text()))
+		return nil
+	}
+}
+
+func TestRawBlock(t *testing.T) {
+	ctx := &rawCtx{msgCtx: msgCtx{list.New(), ""}}
+	err := ParseFile("test_files/parse/raw.txt", ctx)
+	if !errors.Is(err, errBadScript) {
+		t.Fatalf("Got error %v; want %v", err, errBadScript)
+	} else if loc := ErrorLocation(err); loc.Lineno != 10 || loc.Offset != 5 {
+		t.Errorf("Got error at %d:%d; want 10:5", loc.Lineno, loc.Offset)
+	}
+
+	expect := "sh:echo \"unclosed # not a comment\n  indented more\n\ndone\n"
+	if len(ctx.scripts) != 1 || ctx.scripts[0] != expect {
+		t.Errorf("Got scripts %q; want [%q]", ctx.scripts, expect)
+	}
+
+	if ctx.messages.Len() != 1 || ctx.messages.Front().Value != "after" {
+		t.Errorf("Directive after raw block was not parsed")
+	}
+
+	recorder := &TraceRecorder{}
+	ctx = &rawCtx{msgCtx: msgCtx{list.New(), ""}}
+	(&Parser{Tracer: recorder}).ParseFile("test_files/parse/raw.txt", ctx)
+	transcript := recorder.Transcript(TraceToken)
+	if !strings.Contains(transcript, "  token 2:5 text ") {
+		t.Errorf("Raw block missing from token transcript:\n%s", transcript)
+	}
+}
@@ -0,0 +1,230 @@
+package indentfile
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The most suggestions included in an error.
+const maxSuggestions = 3
+
+// ErrorSuggestions returns the directive names suggested by err,
+// closest first,
+// or nil if it makes no suggestions.
+// Errors for unknown directives suggest
+// the known directives with the most similar names.
+func ErrorSuggestions(err error) []string {
+	var suggester interface {
+		suggestions() []string
+	}
+
+	if errors.As(err, &suggester) {
+		return suggester.suggestions()
+	}
+
+	return nil
+}
+
+func (err errorArg) suggestions() []string {
+	return err.Suggestions
+}
+
+func (err errWithLocation) suggestions() []string {
+	return err.Suggestions
+}
+
+// unknownError reports that there is no directive called name,
+// suggesting the closest of the known directives.
+func unknownError(name string, known []string) error {
+	err := DirectiveErrorf("%w %q", ErrUnknown, name).(errorArg)
+	err.Suggestions = suggest(strings.ToLower(name), known)
+	if len(err.Suggestions) > 0 {
+		quoted := make([]string, len(err.Suggestions))
+		for i, suggestion := range err.Suggestions {
+			quoted[i] = `"` + suggestion + `"`
+		}
+
+		err.Detail += " (did you mean " + strings.Join(quoted, " or ") + "?)"
+	}
+
+	return err
+}
+
+// suggest returns the names in known that are close enough to name
+// to be likely typos, closest first.
+func suggest(name string, known []string) []string {
+	maxDistance := len(name) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+
+	type candidate struct {
+		name     string
+		distance int
+	}
+
+	var candidates []candidate
+	for _, k := range known {
+		distance := editDistance(name, k)
+		if distance <= maxDistance {
+			candidates = append(candidates, candidate{k, distance})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+
+		return candidates[i].name < candidates[j].name
+	})
+
+	if len(candidates) > maxSuggestions {
+		candidates = candidates[:maxSuggestions]
+	}
+
+	var names []string
+	for _, c := range candidates {
+		names = append(names, c.name)
+	}
+
+	return names
+}
+
+// editDistance returns the number of single character insertions,
+// deletions, substitutions and transpositions
+// needed to turn a into b.
+func editDistance(a, b string) int {
+	// Only the last three rows of the table are needed.
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	next := make([]int, len(b)+1)
+	for j := range cur {
+		cur[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		next[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			next[j] = cur[j-1] + cost
+			if cur[j]+1 < next[j] {
+				next[j] = cur[j] + 1
+			}
+			if next[j-1]+1 < next[j] {
+				next[j] = next[j-1] + 1
+			}
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] &&
+				prev[j-2]+1 < next[j] {
+				next[j] = prev[j-2] + 1
+			}
+		}
+
+		prev, cur, next = cur, next, prev
+	}
+
+	return cur[len(b)]
+}
+
+// Directive names under the default name mapping.
+// Other mappings can be changed at any time, so they are not cached.
+var (
+	directiveCacheLock sync.RWMutex
+	directiveCache     = map[reflect.Type][]string{}
+)
+
+// directiveNames returns the names of the directives
+// that a reflection context of type t accepts under a name mapping,
+// in sorted order.
+func directiveNames(t reflect.Type, names *NameMapping) []string {
+	if names != defaultNames {
+		return listDirectiveNames(t, names)
+	}
+
+	directiveCacheLock.RLock()
+	directives, cached := directiveCache[t]
+	directiveCacheLock.RUnlock()
+	if cached {
+		return directives
+	}
+
+	directives = listDirectiveNames(t, names)
+
+	directiveCacheLock.Lock()
+	directiveCache[t] = directives
+	directiveCacheLock.Unlock()
+
+	return directives
+}
+
+// listDirectiveNames is directiveNames without the cache.
+func listDirectiveNames(t reflect.Type, names *NameMapping) (directives []string) {
+	for i := 0; i < t.NumMethod(); i++ {
+		method := t.Method(i)
+		if !isDirectiveMethod(method.Name, method.Type) {
+			continue
+		}
+
+		if name, ok := names.directiveName(method.Name); ok {
+			directives = append(directives, name)
+		}
+	}
+
+	sort.Strings(directives)
+	return directives
+}
+
+// isDirectiveMethod reports whether a method of a reflection context
+// can be called as a directive.
+// methodType includes the receiver, as from reflect.Type.Method.
+func isDirectiveMethod(name string, methodType reflect.Type) bool {
+	nargs := methodType.NumIn() - 1
+	nret := methodType.NumOut()
+
+	if nret > 2 || reservedMethods[name] {
+		return false
+	} else if name == "End" && nargs == 0 && nret == 1 &&
+		methodType.Out(0).Implements(reflect.TypeOf((*error)(nil)).Elem()) {
+		return false
+	} else if methodType.IsVariadic() &&
+		methodType.In(nargs).Elem().Kind() != reflect.String {
+		return false
+	}
+
+	return true
+}
+
+// directiveSignature describes the arguments a directive method takes,
+// such as "listen <word> [<word>...]".
+func directiveSignature(name string, methodType reflect.Type) string {
+	nargs := methodType.NumIn()
+	if methodType.IsVariadic() {
+		nargs--
+	}
+
+	parts := []string{name}
+	hasBlock := false
+	for i := 0; i < nargs; i++ {
+		argType := methodType.In(i)
+		if argType == blockType && !hasBlock {
+			hasBlock = true
+		} else if argType.Kind() == reflect.String {
+			parts = append(parts, "<word>")
+		} else {
+			parts = append(parts, "<json>")
+		}
+	}
+
+	if methodType.IsVariadic() {
+		parts = append(parts, "[<word>...]")
+	}
+
+	return strings.Join(parts, " ")
+}
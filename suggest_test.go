@@ -0,0 +1,136 @@
+package indentfile
+
+import (
+	"container/list"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type serverCtx struct{}
+
+func (serverCtx) Listen(addr string, ports ...string)     {}
+func (serverCtx) ListenTLS(addr string, config msgObject) {}
+func (serverCtx) Log(level string)                        {}
+func (serverCtx) X2fa()                                   {}
+func (serverCtx) End() error                              { return nil }
+
+func TestDirectiveNames(t *testing.T) {
+	names := directiveNames(reflect.TypeOf(serverCtx{}), defaultNames)
+	expect := []string{"2fa", "listen", "listen-t-l-s", "log"}
+	if !reflect.DeepEqual(names, expect) {
+		t.Errorf("directiveNames = %q; want %q", names, expect)
+	}
+}
+
+// reservedCtx implements every optional interface of a context.
+type reservedCtx struct{}
+
+func (reservedCtx) Msg(text string)                                           {}
+func (reservedCtx) Directive(name string, argv []string) (interface{}, error) { return nil, nil }
+func (reservedCtx) ObjectDirective(name string, argv []string, json []byte) (interface{}, error) {
+	return nil, nil
+}
+func (reservedCtx) ArgsDirective(name string, args []Arg) (interface{}, error) { return nil, nil }
+func (reservedCtx) End() error                                                 { return nil }
+func (reservedCtx) ParseBlock(tok *Tokenizer) error                            { return nil }
+func (reservedCtx) RawBlock(text Token) error                                  { return nil }
+func (reservedCtx) DirectivePragmas(name string, pragmas []Pragma) error       { return nil }
+func (reservedCtx) DirectiveAliases() map[string]string                        { return nil }
+func (reservedCtx) DeprecatedDirectives() map[string]string                    { return nil }
+func (reservedCtx) Includes() []string                                         { return nil }
+func (reservedCtx) UnknownDirective(name string, args []Arg) (interface{}, error) {
+	return nil, nil
+}
+
+func TestReservedMethods(t *testing.T) {
+	ctxType := reflect.TypeOf(reservedCtx{})
+	for name := range reservedMethods {
+		if _, ok := ctxType.MethodByName(name); !ok {
+			t.Errorf("reservedCtx does not have reserved method %s", name)
+		}
+	}
+
+	names := listDirectiveNames(ctxType, defaultNames)
+	if expect := []string{"msg"}; !reflect.DeepEqual(names, expect) {
+		t.Errorf("listDirectiveNames = %q; want %q", names, expect)
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	tests := []struct {
+		source      string
+		suggestions []string
+		message     string
+	}{
+		{
+			"listne :80",
+			[]string{"listen"},
+			`unknown directive at line 1:1: "listne" (did you mean "listen"?)`,
+		},
+		{
+			"lg debug",
+			[]string{"log"},
+			`unknown directive at line 1:1: "lg" (did you mean "log"?)`,
+		},
+		{
+			"Listen :80",
+			[]string{"listen"},
+			`unknown directive at line 1:1: "Listen" (did you mean "listen"?)`,
+		},
+		{
+			"reticulate splines",
+			nil,
+			`unknown directive at line 1:1: "reticulate"`,
+		},
+		{
+			"listen",
+			nil,
+			`bad argument at line 1:7: not enough arguments (want listen <word> [<word>...])`,
+		},
+		{
+			"log debug verbose",
+			nil,
+			`bad argument at line 1:11: too many arguments (want log <word>)`,
+		},
+		{
+			"listen-t-l-s :443",
+			nil,
+			`bad argument at line 1:18: expected JSON argument (want listen-t-l-s <word> <json>)`,
+		},
+	}
+
+	for _, test := range tests {
+		err := Parse(strings.NewReader(test.source), serverCtx{})
+		if err == nil {
+			t.Errorf("Parse(%q) returned no error", test.source)
+			continue
+		}
+
+		if err.Error() != test.message {
+			t.Errorf("Parse(%q) error = %q; want %q", test.source, err, test.message)
+		}
+
+		suggestions := ErrorSuggestions(err)
+		if !reflect.DeepEqual(suggestions, test.suggestions) {
+			t.Errorf("Parse(%q) suggestions = %q; want %q",
+				test.source, suggestions, test.suggestions)
+		}
+	}
+
+	var warning error
+	err := (&Parser{Unknown: UnknownWarn, Warn: func(err error) {
+		warning = err
+	}}).Parse(strings.NewReader("mgs hello\n"), &msgCtx{list.New(), ""})
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	} else if !errors.Is(warning, ErrUnknown) {
+		t.Fatalf("Got warning %v; want %v", warning, ErrUnknown)
+	}
+
+	suggestions := ErrorSuggestions(warning)
+	if !reflect.DeepEqual(suggestions, []string{"msg"}) {
+		t.Errorf("Warning suggestions = %q; want %q", suggestions, []string{"msg"})
+	}
+}
@@ -18,16 +18,94 @@ type Tokenizer struct {
 	lastWordEnd    int
 	indentStack    list.List
 	outdenting     bool
+	filePragmas    []Pragma
+	pragmas        []Pragma
+	objects        ObjectSyntax
+	objectSyntaxes map[string]ObjectSyntax
+	validate       bool
+	indentPolicy   IndentPolicy
+	limits         TokenizerOptions
+	inputSize      int
+	words          int
+	lookahead      []lookahead
+}
+
+// TokenizerOptions configures a Tokenizer
+// created with NewTokenizerWithOptions.
+type TokenizerOptions struct {
+	// The syntax of object arguments.
+	// If nil, JSON is used.
+	Objects ObjectSyntax
+	// Extra object syntaxes that a file may select by name,
+	// in addition to "json" and "json5".
+	ObjectSyntaxes map[string]ObjectSyntax
+	// Whether to check that every object argument is valid JSON
+	// as it is read.
+	// Otherwise, invalid JSON is only noticed
+	// if and when the handler decodes it.
+	ValidateObjects bool
+	// Whether to decode input starting with a UTF-16 byte order mark.
+	// Otherwise, all input is read as UTF-8.
+	DetectUTF16 bool
+	// The indentation new blocks must follow.
+	Indent IndentPolicy
+	// Whether a "#!" or "#@" comment that can't be read as a pragma
+	// is an error wrapping ErrPragma.
+	// Otherwise, it is treated as an ordinary comment.
+	StrictPragmas bool
+
+	// Limits for reading untrusted input.
+	// Zero means no limit.
+	// Exceeding a limit returns an error wrapping ErrLimit.
+
+	// The maximum number of bytes in a line, excluding the line ending.
+	MaxLineLength int
+	// The maximum number of bytes of input.
+	MaxInputSize int
+	// The maximum depth of nested blocks.
+	MaxDepth int
+	// The maximum number of words in a directive,
+	// including its name and any JSON arguments.
+	MaxWords int
+	// The maximum number of bytes in a JSON argument.
+	MaxObjectSize int
+	// The maximum depth of nested brackets in a JSON argument,
+	// for the built-in object syntaxes.
+	MaxObjectDepth int
 }
 
 // NewTokenizer creates and initialises a new Tokenizer.
 func NewTokenizer(r io.Reader) (t *Tokenizer) {
+	return NewTokenizerWithOptions(r, TokenizerOptions{})
+}
+
+// NewTokenizerWithOptions creates and initialises a new Tokenizer
+// configured by opts.
+func NewTokenizerWithOptions(r io.Reader, opts TokenizerOptions) (t *Tokenizer) {
+	if opts.MaxInputSize > 0 {
+		// Read one byte more, to notice when the limit is exceeded.
+		r = io.LimitReader(r, int64(opts.MaxInputSize)+1)
+	}
+
 	t = &Tokenizer{
 		r:      bufio.NewReader(r),
 		lineno: 0, offset: 0,
-		line:        nil,
-		lastToken:   nilToken,
-		indentStack: list.List{},
+		line:           nil,
+		lastToken:      nilToken,
+		indentStack:    list.List{},
+		objects:        opts.Objects,
+		objectSyntaxes: opts.ObjectSyntaxes,
+		validate:       opts.ValidateObjects,
+		indentPolicy:   opts.Indent,
+		limits:         opts,
+	}
+
+	if t.objects == nil {
+		t.objects = JSON
+	}
+
+	if opts.DetectUTF16 {
+		t.r = bufio.NewReader(newUTF16Reader(t.r))
 	}
 
 	t.indentStack.PushBack([]byte{})
@@ -38,6 +116,16 @@ func NewTokenizer(r io.Reader) (t *Tokenizer) {
 // Next returns the next token in the stream.
 // It returns an error of io.EOF at the end of the file.
 func (t *Tokenizer) Next() (tok Token, err error) {
+	if len(t.lookahead) > 0 {
+		next := t.lookahead[0]
+		t.lookahead = t.lookahead[1:]
+		return next.tok, next.err
+	}
+
+	return t.next()
+}
+
+func (t *Tokenizer) next() (tok Token, err error) {
 	if t.lastToken == errorToken {
 		return nil, io.EOF
 	}
@@ -46,9 +134,7 @@ func (t *Tokenizer) Next() (tok Token, err error) {
 	err = nil
 
 	if t.line == nil {
-		t.lineno++
-		t.offset = 1
-		t.line, err = t.r.ReadBytes('\n')
+		err = t.readLine()
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				err = nil
@@ -60,7 +146,7 @@ func (t *Tokenizer) Next() (tok Token, err error) {
 
 	if len(t.line) <= t.offset-1 {
 		switch t.lastToken {
-		case WordToken:
+		case WordToken, ObjectToken:
 			t.lastToken = TerminatorToken
 			tok = &terminatorToken{
 				info: LineInfo{t.lineno, t.offset, t.line},
@@ -79,6 +165,7 @@ func (t *Tokenizer) Next() (tok Token, err error) {
 					LineInfo{t.lineno, t.offset, t.line},
 					t.indentStack.Back().Value.([]byte),
 				}
+				t.dropBlockPragmas(t.indentStack.Back().Value.([]byte))
 				return
 			}
 
@@ -91,13 +178,13 @@ func (t *Tokenizer) Next() (tok Token, err error) {
 	switch t.line[t.offset-1] {
 	case ' ', '\t':
 		t.offset++
-		return t.Next()
+		return t.next()
 
 	case '\r':
 		if t.offset == len(t.line) || t.line[t.offset] != '\n' {
 			t.lastToken = errorToken
-			err = errorAtf(ErrCRLF, t.info(),
-				"found CR without matching LF")
+			err = withFixes(errorAtf(ErrCRLF, t.info(),
+				"found CR without matching LF"), crFix(t.info()))
 			return
 		}
 
@@ -106,7 +193,7 @@ func (t *Tokenizer) Next() (tok Token, err error) {
 	case '\n':
 		if t.lastToken == nilToken || t.lastToken == TerminatorToken {
 			t.line = nil
-			return t.Next()
+			return t.next()
 		}
 
 		t.lastToken = TerminatorToken
@@ -118,7 +205,7 @@ func (t *Tokenizer) Next() (tok Token, err error) {
 		return
 
 	case '#':
-		if t.lastToken == WordToken {
+		if t.lastToken == WordToken || t.lastToken == ObjectToken {
 			eol := len(t.line)
 			if t.line[eol-1] == '\n' && t.line[eol-2] == '\r' {
 				eol--
@@ -138,10 +225,17 @@ func (t *Tokenizer) Next() (tok Token, err error) {
 			t.line,
 		}}
 		t.line = nil
+
+		err = t.readPragma(tok)
+		if err != nil {
+			t.lastToken = errorToken
+			tok = nil
+		}
+
 		return
 
 	case '{', '[':
-		if t.lastToken != WordToken {
+		if t.lastToken != WordToken && t.lastToken != ObjectToken {
 			t.lastToken = errorToken
 			if t.line[t.offset-1] == '{' {
 				err = errorAtf(ErrToken, t.info(),
@@ -153,6 +247,10 @@ func (t *Tokenizer) Next() (tok Token, err error) {
 			return
 		}
 
+		if err = t.countWord(); err != nil {
+			return
+		}
+
 		return t.nextJSON()
 
 	default:
@@ -161,7 +259,7 @@ func (t *Tokenizer) Next() (tok Token, err error) {
 			err = errorAtf(ErrIndent, t.info(),
 				"first item must be unindented")
 			return
-		} else if t.lastToken == TerminatorToken {
+		} else if t.lastToken == TerminatorToken || t.outdenting {
 			indent := t.line[:t.offset-1]
 			tail := t.indentStack.Back()
 			tailData := tail.Value.([]byte)
@@ -169,13 +267,29 @@ func (t *Tokenizer) Next() (tok Token, err error) {
 				if t.outdenting {
 					// Didn't recognise indent!
 					t.lastToken = errorToken
-					err = errorAt(ErrOutdent, t.info())
+					err = t.outdentError()
 					return
 				}
 
 				if len(indent) != len(tailData) {
 					// More stuff in indent than tailData,
 					// so we've indented.
+					err = t.indentPolicy.Check(tailData, indent)
+					if err != nil {
+						t.lastToken = errorToken
+						err = errorAtf(ErrIndent, LineInfo{
+							t.lineno, len(tailData) + 1, t.line,
+						}, "%v", err)
+						return
+					}
+
+					if max := t.limits.MaxDepth; max > 0 && t.indentStack.Len() > max {
+						t.lastToken = errorToken
+						err = errorAtf(ErrTooDeep, t.info(),
+							"more than %d levels", max)
+						return
+					}
+
 					t.indentStack.PushBack(indent)
 					t.lastToken = IndentToken
 					tok = &indentToken{LineInfo{
@@ -192,34 +306,150 @@ func (t *Tokenizer) Next() (tok Token, err error) {
 				// More stuff in tailData than indent,
 				// so we've outdented.
 
+				if !t.outdenting && !t.hasIndent(indent) {
+					// Didn't recognise indent!
+					t.lastToken = errorToken
+					err = t.outdentError()
+					return
+				}
+
+				// Close one block at a time,
+				// until the indent matches again.
 				t.indentStack.Remove(tail)
 				tail = t.indentStack.Back()
 				tailData = tail.Value.([]byte)
-				if !bytes.Equal(tailData, indent) {
-					t.outdenting = true
-					return t.Next()
-				}
-
-				t.outdenting = false
+				t.outdenting = !bytes.Equal(tailData, indent)
 				t.lastToken = OutdentToken
 				tok = &outdentToken{
 					LineInfo{t.lineno, t.offset, t.line},
 					tailData,
 				}
+				t.dropBlockPragmas(tailData)
 				return
 
 			} else {
 				// Didn't recognise indent!
 				t.lastToken = errorToken
-				err = errorAt(ErrOutdent, t.info())
+				err = t.outdentError()
 				return
 			}
 		}
 
+		if err = t.countWord(); err != nil {
+			return
+		}
+
 		return t.nextWord()
 	}
 }
 
+// outdentError reports that the indent of the current line
+// doesn't match any open block,
+// suggesting the indent of the nearest one.
+func (t *Tokenizer) outdentError() error {
+	indent := t.line[:t.offset-1]
+	var nearest []byte
+	best := -1
+	for e := t.indentStack.Front(); e != nil; e = e.Next() {
+		level := e.Value.([]byte)
+		distance := len(indent) - len(level)
+		if distance < 0 {
+			distance = -distance
+		}
+
+		if best < 0 || distance < best {
+			nearest = level
+			best = distance
+		}
+	}
+
+	return withFixes(errorAt(ErrOutdent, t.info()),
+		reindentFix(t.info(), nearest))
+}
+
+func (t *Tokenizer) hasIndent(indent []byte) bool {
+	for e := t.indentStack.Back(); e != nil; e = e.Prev() {
+		if bytes.Equal(e.Value.([]byte), indent) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readLine reads the next line of input into t.line.
+func (t *Tokenizer) readLine() (err error) {
+	t.lineno++
+	t.offset = 1
+	t.line, err = t.readBytes()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return
+	}
+
+	t.inputSize += len(t.line)
+	if max := t.limits.MaxInputSize; max > 0 && t.inputSize > max {
+		t.offset = len(t.line) - (t.inputSize - max) + 1
+		t.lastToken = errorToken
+		return errorAtf(ErrInputTooLarge, t.info(),
+			"more than %d bytes", max)
+	}
+
+	if t.lineno == 1 {
+		t.line = bytes.TrimPrefix(t.line, utf8BOM)
+	}
+
+	if bad := invalidUTF8(t.line); bad >= 0 {
+		t.offset = bad + 1
+		t.lastToken = errorToken
+		return errorAtf(ErrEncoding, t.info(), "invalid UTF-8")
+	}
+
+	return
+}
+
+// readBytes reads a line of input,
+// stopping early if it is longer than MaxLineLength.
+func (t *Tokenizer) readBytes() ([]byte, error) {
+	max := t.limits.MaxLineLength
+	if max <= 0 {
+		return t.r.ReadBytes('\n')
+	}
+
+	var line []byte
+	for {
+		frag, err := t.r.ReadSlice('\n')
+		line = append(line, frag...)
+
+		// Allow for the line ending.
+		length := len(bytes.TrimRight(line, "\r\n"))
+		if length > max || (err == bufio.ErrBufferFull && len(line) > max+2) {
+			t.line = line
+			t.offset = max + 1
+			t.lastToken = errorToken
+			return nil, errorAtf(ErrLineTooLong, t.info(),
+				"more than %d bytes", max)
+		} else if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+// countWord counts another word in the current directive.
+func (t *Tokenizer) countWord() error {
+	if t.lastToken != WordToken && t.lastToken != ObjectToken {
+		t.words = 0
+	}
+
+	t.words++
+	if max := t.limits.MaxWords; max > 0 && t.words > max {
+		t.lastToken = errorToken
+		return errorAtf(ErrTooManyWords, t.info(),
+			"more than %d", max)
+	}
+
+	return nil
+}
+
 func (t *Tokenizer) info() LineInfo {
 	return LineInfo{t.lineno, t.offset, t.line}
 }
@@ -251,8 +481,8 @@ func (t *Tokenizer) nextWord() (tok Token, err error) {
 				if i+1 == len(t.line) || t.line[i+1] != '\n' {
 					t.lastToken = errorToken
 					tok = nil
-					err = errorAtf(ErrCRLF, t.info(),
-						"found CR without matching LF")
+					err = withFixes(errorAtf(ErrCRLF, t.info(),
+						"found CR without matching LF"), crFix(t.info()))
 					return
 				}
 
@@ -281,15 +511,16 @@ func (t *Tokenizer) nextWord() (tok Token, err error) {
 				// Unpaired CRLF is the more important error.
 				t.lastToken = errorToken
 				tok = nil
-				err = errorAtf(ErrCRLF, t.info(),
-					"found CR without matching LF")
+				err = withFixes(errorAtf(ErrCRLF, t.info(),
+					"found CR without matching LF"), crFix(t.info()))
 				return
 			}
 
 			// Can't have newline in quote!
 			t.lastToken = errorToken
 			tok = nil
-			err = errorAt(ErrUnquote, t.info())
+			err = withFixes(errorAt(ErrUnquote, t.info()),
+				unquoteFix(t.info(), quote))
 			return
 
 		} else {
@@ -315,12 +546,10 @@ func (t *Tokenizer) nextWord() (tok Token, err error) {
 
 func (t *Tokenizer) nextJSON() (tok Token, err error) {
 	json := &jsonToken{}
-	tok = json
-	stack := list.New()
+	scanner := t.objects.NewScanner()
+	depth, hasDepth := scanner.(depthScanner)
 	srcbuf := bytes.NewBuffer(t.line)
 
-	bracket := byte(0)
-	escaped := false
 	ci := t.offset - 1
 	json.srcOffset = ci
 	json.charstops = append(json.charstops, charstop{
@@ -328,9 +557,7 @@ func (t *Tokenizer) nextJSON() (tok Token, err error) {
 	})
 	for {
 		if t.line == nil {
-			t.lineno++
-			t.offset = 1
-			t.line, err = t.r.ReadBytes('\n')
+			err = t.readLine()
 			if err != nil {
 				if errors.Is(err, io.EOF) {
 					t.lastToken = errorToken
@@ -348,57 +575,58 @@ func (t *Tokenizer) nextJSON() (tok Token, err error) {
 		}
 
 		c := t.line[t.offset-1]
-		if bracket == '"' {
-			if c == '\n' {
-				return nil, errorAtf(ErrUnquote, t.info(),
-					"newline in JSON string")
-			} else if escaped {
-				escaped = false
-			} else if c == '\\' {
-				escaped = true
-			} else if c == '"' {
-				back := stack.Back()
-				bracket = back.Value.(byte)
-				stack.Remove(back)
+		if c == '\r' {
+			if t.offset == len(t.line) || t.line[t.offset] != '\n' {
+				t.lastToken = errorToken
+				return nil, withFixes(errorAt(ErrCRLF, t.info()),
+					crFix(t.info()))
 			}
+		}
 
-		} else if c == bracket {
-			back := stack.Back()
+		end, scanErr := scanner.Scan(c)
+		if scanErr != nil {
+			t.lastToken = errorToken
+			return nil, errorAt(scanErr, t.info())
+		}
 
-			if back == nil {
-				t.offset++
-				srcbuf.Truncate(ci + 1)
-				json.src = srcbuf.Bytes()
-				return
-			}
+		if max := t.limits.MaxObjectSize; max > 0 && ci+1-json.srcOffset > max {
+			t.lastToken = errorToken
+			return nil, errorAtf(ErrObjectTooLarge, t.info(),
+				"more than %d bytes", max)
+		}
 
-			bracket = back.Value.(byte)
-			stack.Remove(back)
+		if max := t.limits.MaxObjectDepth; max > 0 && hasDepth &&
+			depth.objectDepth() > max {
+			t.lastToken = errorToken
+			return nil, errorAtf(ErrObjectTooDeep, t.info(),
+				"more than %d levels", max)
+		}
 
-		} else if c == '{' {
-			if bracket != 0 {
-				stack.PushBack(bracket)
+		if end {
+			t.offset++
+			json.src = srcbuf.Bytes()
+			json.srcEnd = ci + 1
+			if normalizer, is := t.objects.(offsetNormalizer); is {
+				json.text, json.offsets, err = normalizer.normalizeOffsets(json.Text())
+			} else {
+				json.text, err = t.objects.Normalize(json.Text())
 			}
 
-			bracket = '}'
-
-		} else if c == '[' {
-			if bracket != 0 {
-				stack.PushBack(bracket)
+			if err != nil {
+				t.lastToken = errorToken
+				return nil, errorAt(err, json.LineInfo(0))
 			}
 
-			bracket = ']'
-
-		} else if c == '"' {
-			stack.PushBack(bracket)
-			bracket = '"'
-
-		} else if c == '\r' {
-			if t.offset == len(t.line) {
-				return nil, errorAt(ErrCRLF, t.info())
-			} else if t.line[t.offset] != '\n' {
-				return nil, errorAt(ErrCRLF, t.info())
+			if t.validate {
+				if err = validateObject(json); err != nil {
+					t.lastToken = errorToken
+					return nil, err
+				}
 			}
+
+			t.lastToken = ObjectToken
+			t.lastWordEnd = t.offset
+			return json, nil
 		}
 
 		ci++
@@ -420,8 +648,10 @@ const (
 	// Text() will return the shell-parsed text of a field.
 	WordToken
 	// Token is a complete JSON object or array.
-	// Full syntactic validation is outside the scope of this interface,
-	// though if the syntax is valid then this is one complete object.
+	// Its syntax is only checked fully
+	// if TokenizerOptions.ValidateObjects is set,
+	// which reports errors at their position in the source.
+	// Otherwise, if the syntax is valid then this is one complete object.
 	// Text() will return JSON source.
 	ObjectToken
 	// Token signals that a directive has ended.
@@ -442,9 +672,16 @@ const (
 	// Token is a comment.
 	// Usually, this token will be ignored,
 	// but may be useful to implement metadirectives.
+	// Comments that are pragmas are also returned as this token,
+	// after being recorded by the Tokenizer (see Pragma).
 	// Text() will return the full text of the comment,
 	// including the leading comment character.
 	CommentToken
+	// Token is an indented block of raw text,
+	// only returned by NextRawBlock.
+	// Text() will return every line of the block
+	// with the block's indentation removed.
+	TextToken
 )
 
 // The Token interface defines the available introspection on a token.
@@ -463,7 +700,8 @@ type Token interface {
 type LineInfo struct {
 	// The 1-based index of the line in the source code
 	Lineno int
-	// The 1-based index of the character at question within the line
+	// The 1-based index of the byte at question within the line.
+	// See Column and UTF16Column for the index in characters.
 	Offset int
 	// The full line of source code at `Lineno`
 	Text []byte
@@ -498,9 +736,15 @@ func (t *wordToken) Text() []byte {
 }
 
 type jsonToken struct {
+	// The source lines the object was read from.
 	src       []byte
 	srcOffset int
+	srcEnd    int
 	charstops []charstop
+	// The normalized JSON, if the source was not already JSON.
+	text []byte
+	// The offset in the source of each byte of text, if known.
+	offsets []int
 }
 
 func (t *jsonToken) Type() TokenType {
@@ -508,11 +752,42 @@ func (t *jsonToken) Type() TokenType {
 }
 
 func (t *jsonToken) LineInfo(at int) LineInfo {
-	return findCharstop(at+t.srcOffset, t.charstops, t.src)
+	if at < 0 {
+		at = 0
+	}
+
+	if t.offsets != nil {
+		if at >= len(t.offsets) {
+			at = len(t.offsets) - 1
+		}
+
+		at = t.offsets[at]
+	}
+
+	at += t.srcOffset
+	if at >= t.srcEnd {
+		at = t.srcEnd - 1
+	}
+
+	info := findCharstop(at, t.charstops, nil)
+
+	start := bytes.LastIndexByte(t.src[:at], '\n') + 1
+	end := bytes.IndexByte(t.src[at:], '\n')
+	if end < 0 {
+		info.Text = t.src[start:]
+	} else {
+		info.Text = t.src[start : at+end+1]
+	}
+
+	return info
 }
 
 func (t *jsonToken) Text() []byte {
-	return t.src[t.srcOffset:]
+	if t.text != nil {
+		return t.text
+	}
+
+	return t.src[t.srcOffset:t.srcEnd]
 }
 
 type terminatorToken struct {
@@ -590,29 +865,14 @@ func (t *commentToken) Text() []byte {
 }
 
 func findCharstop(at int, charstops []charstop, line []byte) LineInfo {
-	lastline := 1
-	startat := 0
-
-	for _, stop := range charstops {
-		if stop.lineno > lastline {
-			lastline = stop.lineno
-			startat = stop.at
-		}
-
+	// Use the last charstop at or before at.
+	for i := len(charstops) - 1; i >= 0; i-- {
+		stop := charstops[i]
 		if stop.at <= at {
-			endl := bytes.IndexByte(line[startat:], '\n')
-			if endl < 0 {
-				return LineInfo{
-					stop.lineno,
-					stop.offset + (at - stop.at),
-					line[startat:],
-				}
-			}
-
 			return LineInfo{
 				stop.lineno,
 				stop.offset + (at - stop.at),
-				line[startat : endl+startat],
+				line,
 			}
 		}
 	}
@@ -139,6 +139,21 @@ func TestJsonSyntax(t *testing.T) {
 	})
 }
 
+func TestMultipleJson(t *testing.T) {
+	testTokenSequence(t, "tokens/multi_json.txt", []expectToken{
+		{WordToken, LineInfo{1, 1, nil}, []byte("route"), nil},
+		{ObjectToken, LineInfo{1, 7, nil}, []byte("[1, 2]"), nil},
+		{WordToken, LineInfo{1, 14, nil}, []byte("/index"), nil},
+		{ObjectToken, LineInfo{1, 21, nil}, []byte(`{"a": 1}`), nil},
+		{TerminatorToken, LineInfo{1, 29, nil}, []byte{'\n'}, nil},
+		{WordToken, LineInfo{2, 1, nil}, []byte("route"), nil},
+		{ObjectToken, LineInfo{2, 7, nil}, []byte("{\"b\": [\n    3\n]}"), nil},
+		{ObjectToken, LineInfo{4, 4, nil}, []byte("[]"), nil},
+		{TerminatorToken, LineInfo{4, 6, nil}, []byte{'\n'}, nil},
+		{CommentToken, LineInfo{4, 7, nil}, []byte("# trailing"), nil},
+	})
+}
+
 func TestMessyWhitespace(t *testing.T) {
 	contents := "\n" +
 		"\t# Notice: this file is re-generated by TestMessyWhitespace.\n" +
@@ -194,6 +209,7 @@ func TestMessyWhitespace(t *testing.T) {
 		{WordToken, LineInfo{11, 5, nil}, []byte("outdent"), nil},
 		{WordToken, LineInfo{11, 13, nil}, []byte("1"), nil},
 		{TerminatorToken, LineInfo{11, 14, nil}, []byte{'\n'}, nil},
+		{OutdentToken, LineInfo{13, 1, nil}, []byte("   "), nil},
 		{OutdentToken, LineInfo{13, 1, nil}, []byte{}, nil},
 		{WordToken, LineInfo{13, 1, nil}, []byte("root"), nil},
 		{TerminatorToken, LineInfo{13, 5, nil}, []byte{'\r', '\n'}, nil},
@@ -295,6 +311,8 @@ func tokenTypeName(t TokenType) string {
 		return "OutdentToken"
 	case CommentToken:
 		return "CommentToken"
+	case TextToken:
+		return "TextToken"
 	default:
 		panic(fmt.Errorf("Unknown token type %v", t))
 	}
@@ -0,0 +1,270 @@
+package indentfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TraceKind identifies the kind of a TraceEvent.
+type TraceKind int
+
+// The valid TraceKind values.
+const (
+	// A token was read from the Tokenizer.
+	// A raw block is traced as a single TextToken.
+	// Tokens read by a TokenBlockHandler,
+	// including those it skips with SkipBlock,
+	// are not traced.
+	TraceToken = TraceKind(iota)
+	// A directive has been handled, successfully or not.
+	TraceDirective
+	// The block of a directive is about to be parsed.
+	TraceBlockEnter
+	// The block of a directive has been parsed.
+	TraceBlockExit
+	// The End method of a context is about to be called.
+	TraceEnd
+	// Parsing failed.
+	TraceError
+)
+
+func (k TraceKind) String() string {
+	switch k {
+	case TraceToken:
+		return "token"
+	case TraceDirective:
+		return "directive"
+	case TraceBlockEnter:
+		return "enter"
+	case TraceBlockExit:
+		return "exit"
+	case TraceEnd:
+		return "end"
+	case TraceError:
+		return "error"
+	default:
+		return fmt.Sprintf("TraceKind(%d)", int(k))
+	}
+}
+
+// TraceEvent describes something that happened while parsing.
+// Fields that don't apply to the Kind of event are left empty.
+type TraceEvent struct {
+	Kind TraceKind
+	// The nesting depth of the block the event happened in,
+	// which is 0 for the top level of the file.
+	Depth int
+	// Where in the source the event happened.
+	Location LineInfo
+	// The file the event happened in, if known.
+	File string
+	// The token read, for TraceToken.
+	Token Token
+	// The name and arguments of the directive, for TraceDirective.
+	Name string
+	Args []Arg
+	// The context handling the event,
+	// such as "*main.Config" or "*main.Config.Listen"
+	// for a directive handled by a reflection method.
+	// For a directive, this is what actually handled it:
+	// "middleware" if middleware did not pass it on,
+	// "WithConditions" for a conditional,
+	// the UnknownDirective method, Unknown field or Parser.Fallback
+	// for a directive the context did not recognise,
+	// or "skipped" or "unknown" as set by Parser.Unknown.
+	Handler string
+	// The error, for TraceError,
+	// or for TraceDirective if the directive failed.
+	Err error
+}
+
+func (e TraceEvent) String() string {
+	var b strings.Builder
+	b.WriteString(strings.Repeat("  ", e.Depth))
+	b.WriteString(e.Kind.String())
+
+	if e.Location.Lineno > 0 {
+		fmt.Fprintf(&b, " %d:%d", e.Location.Lineno, e.Location.Column())
+	}
+
+	switch e.Kind {
+	case TraceToken:
+		fmt.Fprintf(&b, " %s %q", traceTokenName(e.Token.Type()), e.Token.Text())
+
+	case TraceDirective:
+		b.WriteString(" ")
+		b.WriteString(e.Name)
+		for _, arg := range e.Args {
+			b.WriteString(" ")
+			b.WriteString(arg.Text)
+		}
+
+		fmt.Fprintf(&b, " -> %s", e.Handler)
+		if e.Err != nil {
+			fmt.Fprintf(&b, ": %v", e.Err)
+		}
+
+	case TraceError:
+		fmt.Fprintf(&b, " %v", e.Err)
+
+	default:
+		fmt.Fprintf(&b, " %s", e.Handler)
+	}
+
+	return b.String()
+}
+
+func traceTokenName(t TokenType) string {
+	switch t {
+	case WordToken:
+		return "word"
+	case ObjectToken:
+		return "object"
+	case TerminatorToken:
+		return "terminator"
+	case IndentToken:
+		return "indent"
+	case OutdentToken:
+		return "outdent"
+	case CommentToken:
+		return "comment"
+	case TextToken:
+		return "text"
+	default:
+		return fmt.Sprintf("TokenType(%d)", int(t))
+	}
+}
+
+// Tracer receives events from a Parser,
+// to help explain how a file was parsed.
+type Tracer interface {
+	Trace(event TraceEvent)
+}
+
+// TracerFunc adapts a function to a Tracer.
+type TracerFunc func(event TraceEvent)
+
+func (fn TracerFunc) Trace(event TraceEvent) {
+	fn(event)
+}
+
+// TraceRecorder is a Tracer that records every event,
+// for example to compare with an expected transcript in tests.
+type TraceRecorder struct {
+	Events []TraceEvent
+}
+
+func (r *TraceRecorder) Trace(event TraceEvent) {
+	r.Events = append(r.Events, event)
+}
+
+// Transcript returns the recorded events of the given kinds,
+// or of every kind if none are given,
+// with one event per line.
+func (r *TraceRecorder) Transcript(kinds ...TraceKind) string {
+	var b strings.Builder
+	for _, event := range r.Events {
+		if len(kinds) > 0 && !hasTraceKind(kinds, event.Kind) {
+			continue
+		}
+
+		b.WriteString(event.String())
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func hasTraceKind(kinds []TraceKind, kind TraceKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// traceDirective traces a directive that has been handled.
+func (p *Parser) traceDirective(depth int, line []Token, name string, args []Arg, node *Node, handler string, err error) {
+	if p.Tracer == nil {
+		return
+	}
+
+	event := TraceEvent{
+		Kind:     TraceDirective,
+		Depth:    depth,
+		Location: line[0].LineInfo(0),
+		Name:     name,
+		Args:     args,
+		Handler:  handler,
+		Err:      err,
+	}
+
+	if node != nil {
+		event.File = node.File
+	} else {
+		event.File = p.file
+	}
+
+	p.Tracer.Trace(event)
+}
+
+// traceBlock traces an event about the block of context.
+func (p *Parser) traceBlock(kind TraceKind, depth int, location LineInfo, file string, context interface{}) {
+	if p.Tracer == nil {
+		return
+	}
+
+	p.Tracer.Trace(TraceEvent{
+		Kind:     kind,
+		Depth:    depth,
+		Location: location,
+		File:     file,
+		Handler:  fmt.Sprintf("%T", context),
+	})
+}
+
+// traceError traces err if it is not nil,
+// and returns it.
+func (p *Parser) traceError(err error) error {
+	if err == nil || p.Tracer == nil {
+		return err
+	}
+
+	file := p.file
+	if located, is := err.(errWithLocation); is && located.File != "" {
+		file = located.File
+	}
+
+	p.Tracer.Trace(TraceEvent{
+		Kind:     TraceError,
+		Location: ErrorLocation(err),
+		File:     file,
+		Err:      err,
+	})
+
+	return err
+}
+
+// handlerName describes what handled the directive called name
+// in context.
+func (p *Parser) handlerName(context interface{}, name string) string {
+	if cond, is := context.(*conditionalHandler); is {
+		switch name {
+		case "if", "elif", "else":
+			return "WithConditions"
+		}
+
+		context = cond.context
+	}
+
+	if ctx, is := getDirectiveHandlerFor(context, p.Names).(methodDirectiveHandler); is && ctx.value.IsValid() {
+		methodName, method := ctx.names.method(ctx.value, ctx.resolveAlias(name))
+		if method.IsValid() {
+			return fmt.Sprintf("%T.%s", context, methodName)
+		}
+	}
+
+	return fmt.Sprintf("%T", context)
+}
@@ -0,0 +1,75 @@
+//go:build go1.21
+// +build go1.21
+
+package indentfile
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogTracer is a Tracer that writes each event to a slog.Logger.
+type SlogTracer struct {
+	Logger *slog.Logger
+	// The level of directive, block and End events.
+	// Token events are logged one level lower,
+	// and errors at slog.LevelError.
+	Level slog.Level
+}
+
+// NewSlogTracer returns a Tracer that logs events to logger
+// at slog.LevelDebug.
+func NewSlogTracer(logger *slog.Logger) *SlogTracer {
+	return &SlogTracer{Logger: logger, Level: slog.LevelDebug}
+}
+
+func (t *SlogTracer) Trace(event TraceEvent) {
+	level := t.Level
+	switch event.Kind {
+	case TraceToken:
+		level--
+	case TraceError:
+		level = slog.LevelError
+	}
+
+	attrs := []slog.Attr{slog.Int("depth", event.Depth)}
+	if event.File != "" {
+		attrs = append(attrs, slog.String("file", event.File))
+	}
+
+	if event.Location.Lineno > 0 {
+		attrs = append(attrs,
+			slog.Int("line", event.Location.Lineno),
+			slog.Int("column", event.Location.Column()))
+	}
+
+	switch event.Kind {
+	case TraceToken:
+		attrs = append(attrs,
+			slog.String("token", traceTokenName(event.Token.Type())),
+			slog.String("text", string(event.Token.Text())))
+
+	case TraceDirective:
+		args := make([]string, len(event.Args))
+		for i, arg := range event.Args {
+			args[i] = arg.Text
+		}
+
+		attrs = append(attrs,
+			slog.String("name", event.Name),
+			slog.Any("args", args),
+			slog.String("handler", event.Handler))
+		if event.Err != nil {
+			attrs = append(attrs, slog.Any("error", event.Err))
+		}
+
+	case TraceError:
+		attrs = append(attrs, slog.Any("error", event.Err))
+
+	default:
+		attrs = append(attrs, slog.String("handler", event.Handler))
+	}
+
+	t.Logger.LogAttrs(context.Background(), level,
+		"indentfile "+event.Kind.String(), attrs...)
+}
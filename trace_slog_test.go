@@ -0,0 +1,39 @@
+//go:build go1.21
+// +build go1.21
+
+package indentfile
+
+import (
+	"bytes"
+	"container/list"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogTracer(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if attr.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+
+			return attr
+		},
+	}))
+
+	parser := &Parser{Tracer: NewSlogTracer(logger)}
+	err := parser.Parse(strings.NewReader("msg hi there\n"), &msgCtx{list.New(), ""})
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	expect := "level=DEBUG msg=\"indentfile directive\" depth=0 line=1 column=1 " +
+		"name=msg args=\"[hi there]\" handler=*indentfile.msgCtx.Msg\n" +
+		"level=DEBUG msg=\"indentfile end\" depth=0 handler=*indentfile.msgCtx\n"
+	if out.String() != expect {
+		t.Errorf("Got log:\n%s\nwant:\n%s", out.String(), expect)
+	}
+}
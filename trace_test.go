@@ -0,0 +1,170 @@
+package indentfile
+
+import (
+	"container/list"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const simpleTranscript = `directive 2:1 msg hello world -> *indentfile.msgCtx.Msg
+directive 4:1 prefix * -> *indentfile.msgCtx.Prefix
+  enter 5:5 indentfile.msgCtx
+  directive 5:5 msg says hello -> indentfile.msgCtx.Msg
+  directive 6:5 msg waves -> indentfile.msgCtx.Msg
+  directive 8:5 prefix looks -> indentfile.msgCtx.Prefix
+    enter 9:9 indentfile.msgCtx
+    directive 9:9 msg at you -> indentfile.msgCtx.Msg
+    end indentfile.msgCtx
+    exit indentfile.msgCtx
+  directive 11:5 msg unnervingly -> indentfile.msgCtx.Msg
+  end indentfile.msgCtx
+  exit indentfile.msgCtx
+directive 13:1 msg uhhh lets just go -> *indentfile.msgCtx.Msg
+end *indentfile.msgCtx
+`
+
+func TestTrace(t *testing.T) {
+	const path = "test_files/parse/simple.txt"
+	kinds := []TraceKind{TraceDirective, TraceBlockEnter, TraceBlockExit, TraceEnd, TraceError}
+
+	recorder := &TraceRecorder{}
+	err := (&Parser{Tracer: recorder}).ParseFile(path, &msgCtx{list.New(), ""})
+	if err != nil {
+		t.Fatalf("ParseFile returned error: %v", err)
+	}
+
+	if transcript := recorder.Transcript(kinds...); transcript != simpleTranscript {
+		t.Errorf("Got transcript:\n%s\nwant:\n%s", transcript, simpleTranscript)
+	}
+
+	for _, event := range recorder.Events {
+		if event.File != path {
+			t.Errorf("Got %s event in file %q; want %q", event.Kind, event.File, path)
+		}
+	}
+
+	tokens := strings.Split(recorder.Transcript(TraceToken), "\n")
+	expectTokens := []string{
+		`token 2:1 word "msg"`,
+		`token 2:5 word "hello"`,
+		`token 2:11 word "world"`,
+		`token 2:16 terminator "\n"`,
+		`token 4:1 word "prefix"`,
+	}
+
+	for i, expect := range expectTokens {
+		if tokens[i] != expect {
+			t.Errorf("Got token event %d = %q; want %q", i, tokens[i], expect)
+		}
+	}
+
+	doc, err := ReadDocumentFile(path)
+	if err != nil {
+		t.Fatalf("ReadDocumentFile returned error: %v", err)
+	}
+
+	recorder = &TraceRecorder{}
+	err = (&Parser{Tracer: recorder}).ParseDocument(doc, &msgCtx{list.New(), ""})
+	if err != nil {
+		t.Fatalf("ParseDocument returned error: %v", err)
+	}
+
+	if transcript := recorder.Transcript(kinds...); transcript != simpleTranscript {
+		t.Errorf("Got document transcript:\n%s\nwant:\n%s", transcript, simpleTranscript)
+	}
+
+	for _, event := range recorder.Events {
+		if event.File != path {
+			t.Errorf("Got %s event in file %q; want %q", event.Kind, event.File, path)
+		}
+	}
+
+	recorder = &TraceRecorder{}
+	err = (&Parser{Tracer: recorder}).Parse(strings.NewReader("msg hi\nbogus\n"), &msgCtx{list.New(), ""})
+	if !errors.Is(err, ErrUnknown) {
+		t.Fatalf("Got error %v; want %v", err, ErrUnknown)
+	}
+
+	last := recorder.Events[len(recorder.Events)-1]
+	if last.Kind != TraceError || last.Err.Error() != err.Error() || last.Location.Lineno != 2 {
+		t.Errorf("Got last event %s; want error on line 2", last)
+	}
+
+	if n := strings.Count(recorder.Transcript(), "error"); n != 1 {
+		t.Errorf("Got %d error events; want 1", n)
+	}
+
+	// The error traced by ParseFile includes the file.
+	const unknownPath = "test_files/parse/unknown.txt"
+	recorder = &TraceRecorder{}
+	err = (&Parser{Tracer: recorder}).ParseFile(unknownPath, &msgCtx{list.New(), ""})
+	last = recorder.Events[len(recorder.Events)-1]
+	if err == nil || last.Kind != TraceError || last.Err.Error() != err.Error() ||
+		last.File != unknownPath {
+		t.Errorf("Got last event %s in file %q; want error %v in %q",
+			last, last.File, err, unknownPath)
+	}
+}
+
+func TestTraceHandlers(t *testing.T) {
+	deny := func(next ArgsDirectiveHandler) ArgsDirectiveHandler {
+		return ArgsHandlerFunc(func(name string, args []Arg) (interface{}, error) {
+			if name == "bogus" {
+				return nil, errors.New("denied")
+			}
+
+			return next.ArgsDirective(name, args)
+		})
+	}
+
+	fallback := HandlerFunc(func(name string, argv []string) (interface{}, error) {
+		return nil, nil
+	})
+
+	tests := []struct {
+		parser  *Parser
+		context interface{}
+		expect  string
+	}{
+		{&Parser{}, &unknownCtx{msgCtx{list.New(), ""}},
+			"*indentfile.unknownCtx.UnknownDirective"},
+		{&Parser{Fallback: fallback}, &msgCtx{list.New(), ""},
+			"indentfile.HandlerFunc"},
+		{&Parser{Unknown: UnknownSkip}, &msgCtx{list.New(), ""},
+			"skipped"},
+		{&Parser{Unknown: UnknownCollect}, &collectCtx{msgCtx: msgCtx{list.New(), ""}},
+			"*indentfile.collectCtx.Unknown"},
+		{&Parser{Middleware: []Middleware{deny}}, &msgCtx{list.New(), ""},
+			"middleware: denied at line 2:1"},
+		{&Parser{}, &msgCtx{list.New(), ""},
+			`unknown: unknown directive at line 2:1: "bogus"`},
+	}
+
+	for _, test := range tests {
+		recorder := &TraceRecorder{}
+		test.parser.Tracer = recorder
+		test.parser.Parse(strings.NewReader("msg hi\nbogus\n"), test.context)
+
+		transcript := recorder.Transcript(TraceDirective)
+		expect := "directive 2:1 bogus -> " + test.expect + "\n"
+		if !strings.HasSuffix(transcript, expect) {
+			t.Errorf("Got transcript:\n%s\nwant it to end with:\n%s", transcript, expect)
+		}
+	}
+
+	recorder := &TraceRecorder{}
+	source := "if $on\n    msg hi\n"
+	conds := &Conditions{Vars: map[string]string{"on": "1"}}
+	err := (&Parser{Tracer: recorder}).Parse(strings.NewReader(source),
+		WithConditions(&msgCtx{list.New(), ""}, conds))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	expect := "directive 1:1 if $on -> WithConditions\n" +
+		"  directive 2:5 msg hi -> *indentfile.msgCtx.Msg\n"
+	if transcript := recorder.Transcript(TraceDirective); transcript != expect {
+		t.Errorf("Got transcript:\n%s\nwant:\n%s", transcript, expect)
+	}
+}
@@ -0,0 +1,231 @@
+package indentfile
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Includer is implemented by contexts that read other files while parsing,
+// for example with an "include" directive that calls ParseFile.
+// A Watcher also watches every file listed by Includes.
+type Includer interface {
+	Includes() []string
+}
+
+// Watcher keeps a context up to date with a file as it changes.
+//
+// Changes are detected by polling the modification time and size
+// of the file and everything it includes,
+// so no platform-specific notification mechanism is needed.
+// When anything changes, a fresh context is built with New
+// and the file is parsed into it with ParseFile.
+// The new context replaces the current one only if parsing succeeded.
+type Watcher struct {
+	// The file to parse.
+	Path string
+	// New builds an empty context for each attempt to parse the file.
+	New func() interface{}
+	// If not nil, the Parser whose options are used to parse the file.
+	Parser *Parser
+	// How often to check for changes once started.
+	// If zero, the files are checked every second.
+	Interval time.Duration
+	// OnReload, if set, is called with each new context after it is swapped in.
+	OnReload func(context interface{})
+	// OnError, if set, is called whenever reading the files fails.
+	// The previous context remains current.
+	OnError func(err error)
+	// OnReload and OnError are called during a check,
+	// so they must not call Stop, which waits for the check to finish.
+
+	current atomic.Value
+	mutex   sync.Mutex
+	stamps  map[string]fileStamp
+	// Guards stop and done,
+	// separately from mutex so that Stop can wait for a check.
+	running sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+type watchedContext struct {
+	context interface{}
+}
+
+// NewWatcher creates a Watcher for the file at path.
+// It does not read the file until Load, Check or Start is called.
+func NewWatcher(path string, factory func() interface{}) *Watcher {
+	return &Watcher{Path: path, New: factory}
+}
+
+// Current returns the most recent context that was parsed successfully,
+// or nil if there has not been one yet.
+// It is safe to call from any goroutine.
+func (w *Watcher) Current() interface{} {
+	if current, is := w.current.Load().(*watchedContext); is {
+		return current.context
+	}
+
+	return nil
+}
+
+// Load parses the file immediately, whether or not it has changed.
+func (w *Watcher) Load() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.reload()
+}
+
+// Check parses the file again if it or any of its includes have changed
+// since they were last read.
+// It reports whether a new context was swapped in.
+func (w *Watcher) Check() (bool, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.stamps != nil && !w.changed() {
+		return false, nil
+	}
+
+	err := w.reload()
+	return err == nil, err
+}
+
+// Start checks for changes in the background until Stop is called.
+// Errors are only reported through OnError.
+// Calling Start again before Stop does nothing.
+func (w *Watcher) Start() {
+	w.running.Lock()
+	defer w.running.Unlock()
+
+	if w.stop != nil {
+		return
+	}
+
+	interval := w.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	w.stop = stop
+	w.done = done
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			w.Check()
+
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops checking for changes,
+// waiting for any check in progress to finish.
+func (w *Watcher) Stop() {
+	w.running.Lock()
+	defer w.running.Unlock()
+
+	if w.stop == nil {
+		return
+	}
+
+	close(w.stop)
+	<-w.done
+	w.stop = nil
+}
+
+func (w *Watcher) changed() bool {
+	for path, stamp := range w.stamps {
+		if !statFile(path).same(stamp) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *Watcher) reload() error {
+	// Take the stamps before parsing,
+	// so that changes made during parsing are noticed next time.
+	started := time.Now()
+	stamps := map[string]fileStamp{w.Path: statFile(w.Path)}
+	for path := range w.stamps {
+		stamps[path] = statFile(path)
+	}
+
+	parser := w.Parser
+	if parser == nil {
+		parser = &Parser{}
+	}
+
+	context := w.New()
+	err := parser.ParseFile(w.Path, context)
+	if err != nil {
+		// Keep watching the old set of files,
+		// so that fixing the error is noticed.
+		w.stamps = stamps
+		if w.OnError != nil {
+			w.OnError(err)
+		}
+
+		return err
+	}
+
+	w.stamps = map[string]fileStamp{w.Path: stamps[w.Path]}
+	if includer, is := context.(Includer); is {
+		for _, path := range includer.Includes() {
+			if stamp, has := stamps[path]; has {
+				w.stamps[path] = stamp
+			} else if stamp = statFile(path); stamp.modTime.Before(started) {
+				w.stamps[path] = stamp
+			} else {
+				// The file is new to the watcher,
+				// so it was not stamped before parsing,
+				// and may have changed while it was read.
+				// Leave it unstamped so that it is read again.
+				w.stamps[path] = fileStamp{}
+			}
+		}
+	}
+
+	w.current.Store(&watchedContext{context})
+	if w.OnReload != nil {
+		w.OnReload(context)
+	}
+
+	return nil
+}
+
+func (s fileStamp) same(other fileStamp) bool {
+	return s.exists == other.exists && s.size == other.size &&
+		s.modTime.Equal(other.modTime)
+}
+
+func statFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+
+	return fileStamp{info.ModTime(), info.Size(), true}
+}
@@ -0,0 +1,171 @@
+package indentfile
+
+import (
+	"container/list"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	mainPath := filepath.Join(dir, "main.txt")
+	incPath := filepath.Join(dir, "inc.txt")
+
+	stamp := time.Now().Add(-time.Hour)
+	write := func(path, contents string) {
+		err := os.WriteFile(path, []byte(contents), 0666)
+		if err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+
+		// Bump the modification time explicitly,
+		// in case the filesystem's resolution is too coarse.
+		stamp = stamp.Add(time.Second)
+		os.Chtimes(path, stamp, stamp)
+	}
+
+	write(mainPath, "msg one\ninclude "+incPath+"\n")
+	write(incPath, "msg two\n")
+
+	reloads := 0
+	var errs []error
+	w := NewWatcher(mainPath, func() interface{} {
+		return &includeCtx{msgCtx: msgCtx{list.New(), ""}}
+	})
+	w.OnReload = func(interface{}) { reloads++ }
+	w.OnError = func(err error) { errs = append(errs, err) }
+
+	if w.Current() != nil {
+		t.Fatalf("Current() before loading is not nil")
+	}
+
+	if reloaded, err := w.Check(); !reloaded || err != nil {
+		t.Fatalf("First Check() = %v, %v; want true, nil", reloaded, err)
+	}
+
+	testWatcherMessages(t, w, "msg one", "msg two")
+
+	if reloaded, err := w.Check(); reloaded || err != nil {
+		t.Fatalf("Unchanged Check() = %v, %v; want false, nil", reloaded, err)
+	}
+
+	write(incPath, "msg three\n")
+	if reloaded, err := w.Check(); !reloaded || err != nil {
+		t.Fatalf("Check() after include changed = %v, %v; want true, nil",
+			reloaded, err)
+	}
+
+	testWatcherMessages(t, w, "msg one", "msg three")
+
+	write(mainPath, "msg one\nbogus\n")
+	if reloaded, err := w.Check(); reloaded || !errors.Is(err, ErrUnknown) {
+		t.Fatalf("Check() after bad change = %v, %v; want false, ErrUnknown",
+			reloaded, err)
+	}
+
+	testWatcherMessages(t, w, "msg one", "msg three")
+
+	if reloads != 2 || len(errs) != 1 {
+		t.Errorf("Got %d reloads and %d errors; want 2 and 1",
+			reloads, len(errs))
+	}
+
+	// Reloads now happen in the background,
+	// so report them over a channel instead.
+	reloaded := make(chan struct{}, 1)
+	w.OnReload = func(context interface{}) {
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+	}
+	w.OnError = nil
+
+	w.Interval = time.Millisecond
+	w.Start()
+	w.Start()
+
+	// A check may see the file half written,
+	// so wait for the reload with the new contents.
+	write(mainPath, "msg four\n")
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case <-reloaded:
+			ctx := w.Current().(*includeCtx)
+			done = ctx.messages.Front().Value.(string) == "four"
+		case <-timeout:
+			t.Fatalf("Background polling did not reload the changed file")
+		}
+	}
+
+	w.Stop()
+	w.Stop()
+}
+
+func TestWatcherOptions(t *testing.T) {
+	dir := t.TempDir()
+	mainPath := filepath.Join(dir, "main.txt")
+	incPath := filepath.Join(dir, "inc.txt")
+
+	if err := os.WriteFile(mainPath, []byte("bogus\ninclude "+incPath+"\n"), 0666); err != nil {
+		t.Fatalf("Failed to write %s: %v", mainPath, err)
+	}
+
+	// An include modified after parsing started
+	// may have changed while it was read.
+	if err := os.WriteFile(incPath, []byte("msg two\n"), 0666); err != nil {
+		t.Fatalf("Failed to write %s: %v", incPath, err)
+	}
+
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(incPath, future, future)
+
+	w := NewWatcher(mainPath, func() interface{} {
+		return &includeCtx{msgCtx: msgCtx{list.New(), ""}}
+	})
+	w.Parser = &Parser{Unknown: UnknownSkip}
+
+	if err := w.Load(); err != nil {
+		t.Fatalf("Load() with UnknownSkip returned error: %v", err)
+	}
+
+	if reloaded, err := w.Check(); !reloaded || err != nil {
+		t.Errorf("Check() after include changed during parse = %v, %v; want true, nil",
+			reloaded, err)
+	}
+}
+
+func testWatcherMessages(t *testing.T, w *Watcher, expect ...string) {
+	// Both files end with an "<end>" message.
+	ctx := w.Current().(*includeCtx)
+	if ctx.messages.Len() != len(expect)+2 {
+		t.Fatalf("Got %d messages; want %d", ctx.messages.Len(), len(expect)+2)
+	}
+
+	node := ctx.messages.Front()
+	for _, msg := range expect {
+		if node.Value.(string) != msg[len("msg "):] {
+			t.Errorf("Got %q; want %q", node.Value, msg[len("msg "):])
+		}
+
+		node = node.Next()
+	}
+}
+
+type includeCtx struct {
+	msgCtx
+	includes []string
+}
+
+func (ctx *includeCtx) Include(path string) error {
+	ctx.includes = append(ctx.includes, path)
+	return ParseFile(path, &ctx.msgCtx)
+}
+
+func (ctx *includeCtx) Includes() []string {
+	return ctx.includes
+}